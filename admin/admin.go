@@ -0,0 +1,163 @@
+// Package admin 提供可选的健康检查和管理http接口
+//
+//	GET  /healthz  存活检查,进程能响应就返回200,返回体中带有WebSocket连接状态和最后一次心跳回复时间
+//	GET  /readyz   就绪检查,WebSocket未连接或者心跳超时返回503
+//	GET  /plugins  列出已加载的插件
+//	POST /plugins  启用或停用插件 {"name":"RepeatPlugin","enabled":false}
+//	POST /send     手动发送文本消息 {"toUser":"xxx@chatroom","content":"hello","atList":[]}
+//
+// /healthz只用于存活探针,断线时机器人会自动重连,不需要重启进程;
+// 判断机器人当前能否收发消息请使用/readyz
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/chatrbot/chatbot-go"
+)
+
+// 心跳每10s发送一次,超过这个时间没有回复认为连接不可用
+const defaultPongTimeout = 30 * time.Second
+
+// Server 管理接口
+type Server struct {
+	bot *chatbot.ChatBot
+	mux *http.ServeMux
+
+	// Token 不为空时/plugins和/send需要携带 Authorization: Bearer {Token}
+	Token string
+	// PongTimeout 就绪检查允许的最长心跳间隔
+	PongTimeout time.Duration
+}
+
+// New 新建管理接口
+// @token 管理接口的鉴权token,为空时不鉴权,仅建议在只监听本地地址时使用
+func New(bot *chatbot.ChatBot, token string) *Server {
+	s := &Server{
+		bot:         bot,
+		mux:         http.NewServeMux(),
+		Token:       token,
+		PongTimeout: defaultPongTimeout,
+	}
+	s.mux.HandleFunc("/healthz", s.healthz)
+	s.mux.HandleFunc("/readyz", s.readyz)
+	s.mux.HandleFunc("/plugins", s.auth(s.plugins))
+	s.mux.HandleFunc("/send", s.auth(s.send))
+	return s
+}
+
+// ListenAndServe 在addr上启动管理接口
+func (s *Server) ListenAndServe(addr string) error {
+	return http.ListenAndServe(addr, s)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+type healthResponse struct {
+	Status    string     `json:"status"`
+	Connected bool       `json:"connected"`
+	LastPong  *time.Time `json:"lastPong"`
+}
+
+func (s *Server) health() (*healthResponse, bool) {
+	rsp := &healthResponse{Status: "ok", Connected: s.bot.Connected()}
+	ready := rsp.Connected
+	if t := s.bot.LastPong(); !t.IsZero() {
+		rsp.LastPong = &t
+		if time.Since(t) > s.PongTimeout {
+			ready = false
+		}
+	}
+	if !ready {
+		rsp.Status = "unavailable"
+	}
+	return rsp, ready
+}
+
+// healthz 存活检查,连接不可用时返回体中status为unavailable,但状态码仍为200
+func (s *Server) healthz(w http.ResponseWriter, _ *http.Request) {
+	rsp, _ := s.health()
+	writeJSON(w, http.StatusOK, rsp)
+}
+
+func (s *Server) readyz(w http.ResponseWriter, _ *http.Request) {
+	rsp, ready := s.health()
+	code := http.StatusOK
+	if !ready {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, rsp)
+}
+
+func (s *Server) plugins(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.bot.Plugins())
+	case http.MethodPost:
+		var req chatbot.PluginState
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if err := s.bot.SetPluginEnabled(req.Name, req.Enabled); err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, s.bot.Plugins())
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) send(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req chatbot.SendTextRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.ToUser == "" || req.Content == "" {
+		writeError(w, http.StatusBadRequest, errMissingField)
+		return
+	}
+	if err := s.bot.WithContext(r.Context()).SendText(req.ToUser, req.Content, req.AtList); err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// auth 校验管理接口token
+func (s *Server) auth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.Token != "" {
+			want := "Bearer " + s.Token
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(want)) != 1 {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+var errMissingField = errors.New("toUser and content are required")
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,138 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/chatrbot/chatbot-go"
+	"github.com/gorilla/websocket"
+)
+
+type nopPlugin struct{ name string }
+
+func (p *nopPlugin) Name() string                      { return p.name }
+func (p *nopPlugin) Do(msg *chatbot.PushMessage) error { return nil }
+
+// newTestBot 连接到模拟服务端的机器人,sent记录发送的文本
+func newTestBot(t *testing.T, sent *[]chatbot.SendTextRequest) *chatbot.ChatBot {
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ws" {
+			c, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer c.Close()
+			for {
+				if _, _, err := c.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}
+		var req chatbot.SendTextRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		*sent = append(*sent, req)
+		_, _ = w.Write([]byte(`{"code":0,"data":{}}`))
+	}))
+	t.Cleanup(srv.Close)
+	bot, err := chatbot.New(strings.TrimPrefix(srv.URL, "http://"), "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(bot.Close)
+	return bot
+}
+
+func do(s *Server, method, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHealth(t *testing.T) {
+	var sent []chatbot.SendTextRequest
+	bot := newTestBot(t, &sent)
+	s := New(bot, "secret")
+
+	for _, path := range []string{"/healthz", "/readyz"} {
+		rec := do(s, http.MethodGet, path, "", "")
+		var rsp healthResponse
+		_ = json.NewDecoder(rec.Body).Decode(&rsp)
+		if rec.Code != http.StatusOK || rsp.Status != "ok" || !rsp.Connected {
+			t.Errorf("%s: unexpected response %d %+v", path, rec.Code, rsp)
+		}
+	}
+
+	// 断线后就绪检查失败,存活检查仍然返回200
+	bot.Close()
+	if rec := do(s, http.MethodGet, "/readyz", "", ""); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("want 503 when disconnected, got %d", rec.Code)
+	}
+	rec := do(s, http.MethodGet, "/healthz", "", "")
+	var rsp healthResponse
+	_ = json.NewDecoder(rec.Body).Decode(&rsp)
+	if rec.Code != http.StatusOK || rsp.Status != "unavailable" || rsp.Connected {
+		t.Errorf("unexpected liveness response %d %+v", rec.Code, rsp)
+	}
+}
+
+func TestPlugins(t *testing.T) {
+	var sent []chatbot.SendTextRequest
+	bot := newTestBot(t, &sent)
+	bot.Use(&nopPlugin{name: "repeater"})
+	s := New(bot, "secret")
+
+	for _, token := range []string{"", "wrong"} {
+		if rec := do(s, http.MethodGet, "/plugins", token, ""); rec.Code != http.StatusUnauthorized {
+			t.Errorf("token %q: want 401, got %d", token, rec.Code)
+		}
+		if rec := do(s, http.MethodPost, "/plugins", token, `{"name":"repeater","enabled":false}`); rec.Code != http.StatusUnauthorized {
+			t.Errorf("token %q: want 401, got %d", token, rec.Code)
+		}
+	}
+	if !bot.Plugins()[0].Enabled {
+		t.Fatal("want plugin untouched by rejected requests")
+	}
+
+	rec := do(s, http.MethodPost, "/plugins", "secret", `{"name":"repeater","enabled":false}`)
+	var states []chatbot.PluginState
+	_ = json.NewDecoder(rec.Body).Decode(&states)
+	if rec.Code != http.StatusOK || len(states) != 1 || states[0].Enabled {
+		t.Errorf("want plugin disabled, got %d %+v", rec.Code, states)
+	}
+	if rec := do(s, http.MethodPost, "/plugins", "secret", `{"name":"repeater","enabled":true}`); rec.Code != http.StatusOK || !bot.Plugins()[0].Enabled {
+		t.Errorf("want plugin enabled, got %d", rec.Code)
+	}
+	if rec := do(s, http.MethodPost, "/plugins", "secret", `{"name":"missing","enabled":true}`); rec.Code != http.StatusNotFound {
+		t.Errorf("want 404 for unknown plugin, got %d", rec.Code)
+	}
+	if rec := do(s, http.MethodPost, "/plugins", "secret", `{`); rec.Code != http.StatusBadRequest {
+		t.Errorf("want 400 for bad body, got %d", rec.Code)
+	}
+}
+
+func TestSend(t *testing.T) {
+	var sent []chatbot.SendTextRequest
+	bot := newTestBot(t, &sent)
+	s := New(bot, "secret")
+
+	if rec := do(s, http.MethodPost, "/send", "", `{"toUser":"wxid_a","content":"hi"}`); rec.Code != http.StatusUnauthorized {
+		t.Errorf("want 401, got %d", rec.Code)
+	}
+	if rec := do(s, http.MethodPost, "/send", "secret", `{"toUser":"wxid_a"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("want 400 for missing content, got %d", rec.Code)
+	}
+	if rec := do(s, http.MethodPost, "/send", "secret", `{"toUser":"wxid_a","content":"hi"}`); rec.Code != http.StatusOK {
+		t.Errorf("want 200, got %d", rec.Code)
+	}
+	if len(sent) != 1 || sent[0].ToUser != "wxid_a" || sent[0].Content != "hi" {
+		t.Errorf("unexpected sent messages %+v", sent)
+	}
+}
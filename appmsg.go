@@ -0,0 +1,329 @@
+package chatbot
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// MsgTypeApplet消息中appmsg的type字段,用于区分具体的子类型
+const (
+	AppMsgTypeText        = 1    // 文本
+	AppMsgTypeMusic       = 3    // 音乐
+	AppMsgTypeLink        = 5    // 分享链接
+	AppMsgTypeFile        = 6    // 文件
+	AppMsgTypeChatHistory = 19   // 聊天记录
+	AppMsgTypeMiniProgram = 33   // 小程序
+	AppMsgTypeMiniApp     = 36   // 小程序(另一种分享形式)
+	AppMsgTypeQuote       = 57   // 引用回复
+	AppMsgTypeTransfer    = 2000 // 转账
+	AppMsgTypeRedPacket   = 2001 // 红包
+)
+
+// AppMessage 解析后的appmsg
+// 根据Type只会有一个对应的子类型字段不为nil,未支持的类型可以使用通用字段或者Raw自行处理
+type AppMessage struct {
+	Type     int    // appmsg的type
+	AppID    string // 来源应用id
+	Title    string // 标题
+	Des      string // 描述
+	Url      string // 地址
+	ThumbUrl string // 缩略图地址
+	FromUser string // 发送人微信号
+	Raw      string // 原始xml
+
+	Link        *LinkInfo
+	File        *FileInfo
+	Music       *MusicInfo
+	ChatHistory *ChatHistoryInfo
+	Transfer    *TransferInfo
+	MiniProgram *MiniProgramInfo
+	Quote       *QuoteInfo
+}
+
+// LinkInfo 分享链接
+type LinkInfo struct {
+	Title             string
+	Des               string
+	Url               string
+	ThumbUrl          string
+	SourceUserName    string // 来源公众号
+	SourceDisplayName string // 来源公众号名称
+}
+
+// ToSendRequest 转换为发送链接卡片的请求,用于转发收到的链接
+func (l *LinkInfo) ToSendRequest(toUser string) *SendLinkRequest {
+	return &SendLinkRequest{
+		ToUser:   toUser,
+		Title:    l.Title,
+		Des:      l.Des,
+		Url:      l.Url,
+		ThumbUrl: l.ThumbUrl,
+	}
+}
+
+// FileInfo 文件
+type FileInfo struct {
+	Title        string // 文件名
+	TotalLen     int64  // 文件大小
+	FileExt      string // 扩展名
+	AttachID     string
+	CdnAttachUrl string
+	AesKey       string
+	Md5          string
+}
+
+// MusicInfo 音乐
+type MusicInfo struct {
+	Title    string
+	Des      string
+	Url      string // 音乐页面地址
+	DataUrl  string // 音乐数据地址
+	LowUrl   string // 低码率页面地址
+	ThumbUrl string
+}
+
+// ChatHistoryInfo 合并转发的聊天记录
+type ChatHistoryInfo struct {
+	Title      string
+	Des        string // 聊天记录摘要
+	RecordItem string // 聊天记录详情xml
+}
+
+// TransferInfo 转账
+type TransferInfo struct {
+	PaySubType    int    // 1发起转账,3已收款,4已退还
+	FeeDesc       string // 金额描述,例如￥0.01
+	TransactionID string
+	TransferID    string
+	Memo          string // 转账备注
+}
+
+// MiniProgramInfo 小程序
+type MiniProgramInfo struct {
+	Title             string
+	Des               string
+	Url               string
+	ThumbUrl          string
+	SourceUserName    string
+	SourceDisplayName string
+	Username          string // 小程序原始id
+	AppId             string // 小程序AppId
+	Type              int
+	Version           int
+	IconUrl           string
+	PagePath          string // 启动页
+}
+
+// QuoteInfo 引用回复
+type QuoteInfo struct {
+	Text  string       // 回复的内容
+	Refer ReferMessage // 被引用的消息
+}
+
+// ReferMessage 被引用的消息
+type ReferMessage struct {
+	Type        int    // 被引用消息的MsgType
+	NewMsgID    int64  // 被引用消息的服务端id,即UserMessage中的NewMsgID
+	FromUser    string // 被引用消息所在会话,群消息时为群号
+	ChatUser    string // 被引用消息的发送人微信号
+	DisplayName string // 被引用消息的发送人昵称
+	Content     string // 被引用消息的内容,非文本消息时为xml
+	CreateTime  int64
+}
+
+// ToSendRequest 转换为发送小程序的请求,用于转发收到的小程序
+func (m *MiniProgramInfo) ToSendRequest(toUser string) *SendMiniProgramRequest {
+	return &SendMiniProgramRequest{
+		ToUser:            toUser,
+		ThumbUrl:          m.ThumbUrl,
+		Title:             m.Title,
+		Des:               m.Des,
+		Url:               m.Url,
+		SourceUserName:    m.SourceUserName,
+		SourceDisplayName: m.SourceDisplayName,
+		Username:          m.Username,
+		AppId:             m.AppId,
+		Type:              m.Type,
+		Version:           m.Version,
+		IconUrl:           m.IconUrl,
+		PagePath:          m.PagePath,
+	}
+}
+
+// appMsgXML appmsg的xml结构
+type appMsgXML struct {
+	AppID     string `xml:"appid,attr"`
+	Title     string `xml:"title"`
+	Des       string `xml:"des"`
+	Type      int    `xml:"type"`
+	Url       string `xml:"url"`
+	LowUrl    string `xml:"lowurl"`
+	DataUrl   string `xml:"dataurl"`
+	ThumbUrl  string `xml:"thumburl"`
+	Md5       string `xml:"md5"`
+	AppAttach struct {
+		TotalLen     int64  `xml:"totallen"`
+		AttachID     string `xml:"attachid"`
+		FileExt      string `xml:"fileext"`
+		CdnAttachUrl string `xml:"cdnattachurl"`
+		AesKey       string `xml:"aeskey"`
+		CdnThumbUrl  string `xml:"cdnthumburl"`
+	} `xml:"appattach"`
+	SourceUserName    string `xml:"sourceusername"`
+	SourceDisplayName string `xml:"sourcedisplayname"`
+	WeAppInfo         struct {
+		Username string `xml:"username"`
+		AppID    string `xml:"appid"`
+		Type     int    `xml:"type"`
+		Version  int    `xml:"version"`
+		IconUrl  string `xml:"weappiconurl"`
+		PagePath string `xml:"pagepath"`
+	} `xml:"weappinfo"`
+	RecordItem string `xml:"recorditem"`
+	WcPayInfo  struct {
+		PaySubType    int    `xml:"paysubtype"`
+		FeeDesc       string `xml:"feedesc"`
+		TransactionID string `xml:"transcationid"`
+		TransferID    string `xml:"transferid"`
+		Memo          string `xml:"pay_memo"`
+	} `xml:"wcpayinfo"`
+	ReferMsg struct {
+		Type        int    `xml:"type"`
+		SvrID       string `xml:"svrid"`
+		FromUser    string `xml:"fromusr"`
+		ChatUser    string `xml:"chatusr"`
+		DisplayName string `xml:"displayname"`
+		Content     string `xml:"content"`
+		CreateTime  int64  `xml:"createtime"`
+	} `xml:"refermsg"`
+}
+
+// ParseAppMessage 解析MsgTypeApplet类型消息中的appmsg
+func ParseAppMessage(content string) (*AppMessage, error) {
+	var (
+		raw      appMsgXML
+		found    bool
+		fromUser string
+	)
+	d := xml.NewDecoder(strings.NewReader(strings.TrimSpace(content)))
+	d.Strict = false
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch se.Name.Local {
+		case "appmsg":
+			if err := d.DecodeElement(&raw, &se); err != nil {
+				return nil, err
+			}
+			found = true
+		case "fromusername":
+			if err := d.DecodeElement(&fromUser, &se); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if !found {
+		return nil, errors.New("element appmsg not found")
+	}
+
+	m := &AppMessage{
+		Type:     raw.Type,
+		AppID:    raw.AppID,
+		Title:    raw.Title,
+		Des:      raw.Des,
+		Url:      raw.Url,
+		ThumbUrl: raw.ThumbUrl,
+		FromUser: fromUser,
+		Raw:      content,
+	}
+	switch raw.Type {
+	case AppMsgTypeLink:
+		m.Link = &LinkInfo{
+			Title:             raw.Title,
+			Des:               raw.Des,
+			Url:               raw.Url,
+			ThumbUrl:          raw.ThumbUrl,
+			SourceUserName:    raw.SourceUserName,
+			SourceDisplayName: raw.SourceDisplayName,
+		}
+	case AppMsgTypeFile:
+		m.File = &FileInfo{
+			Title:        raw.Title,
+			TotalLen:     raw.AppAttach.TotalLen,
+			FileExt:      raw.AppAttach.FileExt,
+			AttachID:     raw.AppAttach.AttachID,
+			CdnAttachUrl: raw.AppAttach.CdnAttachUrl,
+			AesKey:       raw.AppAttach.AesKey,
+			Md5:          raw.Md5,
+		}
+	case AppMsgTypeMusic:
+		m.Music = &MusicInfo{
+			Title:    raw.Title,
+			Des:      raw.Des,
+			Url:      raw.Url,
+			DataUrl:  raw.DataUrl,
+			LowUrl:   raw.LowUrl,
+			ThumbUrl: raw.ThumbUrl,
+		}
+	case AppMsgTypeChatHistory:
+		m.ChatHistory = &ChatHistoryInfo{
+			Title:      raw.Title,
+			Des:        raw.Des,
+			RecordItem: raw.RecordItem,
+		}
+	case AppMsgTypeTransfer:
+		m.Transfer = &TransferInfo{
+			PaySubType:    raw.WcPayInfo.PaySubType,
+			FeeDesc:       raw.WcPayInfo.FeeDesc,
+			TransactionID: raw.WcPayInfo.TransactionID,
+			TransferID:    raw.WcPayInfo.TransferID,
+			Memo:          raw.WcPayInfo.Memo,
+		}
+	case AppMsgTypeQuote:
+		svrID, _ := strconv.ParseInt(strings.TrimSpace(raw.ReferMsg.SvrID), 10, 64)
+		m.Quote = &QuoteInfo{
+			Text: raw.Title,
+			Refer: ReferMessage{
+				Type:        raw.ReferMsg.Type,
+				NewMsgID:    svrID,
+				FromUser:    raw.ReferMsg.FromUser,
+				ChatUser:    raw.ReferMsg.ChatUser,
+				DisplayName: raw.ReferMsg.DisplayName,
+				Content:     raw.ReferMsg.Content,
+				CreateTime:  raw.ReferMsg.CreateTime,
+			},
+		}
+	case AppMsgTypeMiniProgram, AppMsgTypeMiniApp:
+		thumbUrl := raw.ThumbUrl
+		if thumbUrl == "" {
+			thumbUrl = raw.AppAttach.CdnThumbUrl
+		}
+		m.MiniProgram = &MiniProgramInfo{
+			Title:             raw.Title,
+			Des:               raw.Des,
+			Url:               raw.Url,
+			ThumbUrl:          thumbUrl,
+			SourceUserName:    raw.SourceUserName,
+			SourceDisplayName: raw.SourceDisplayName,
+			Username:          raw.WeAppInfo.Username,
+			AppId:             raw.WeAppInfo.AppID,
+			Type:              raw.WeAppInfo.Type,
+			Version:           raw.WeAppInfo.Version,
+			IconUrl:           raw.WeAppInfo.IconUrl,
+			PagePath:          raw.WeAppInfo.PagePath,
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,78 @@
+package chatbot
+
+import "testing"
+
+const testMiniProgramXML = `<?xml version="1.0"?>
+<msg>
+	<appmsg appid="" sdkver="0">
+		<title>肯德基自助点餐</title>
+		<des>肯德基+</des>
+		<type>33</type>
+		<url>https://mp.weixin.qq.com/mp/waerrpage?appid=wx23dde3ba32269caa</url>
+		<appattach>
+			<cdnthumburl>3057020100044b30</cdnthumburl>
+		</appattach>
+		<sourceusername>gh_50338e5b8c9d@app</sourceusername>
+		<sourcedisplayname>肯德基+</sourcedisplayname>
+		<weappinfo>
+			<username><![CDATA[gh_50338e5b8c9d@app]]></username>
+			<appid><![CDATA[wx23dde3ba32269caa]]></appid>
+			<type>2</type>
+			<version>92</version>
+			<weappiconurl><![CDATA[http://mmbiz.qpic.cn/icon.png]]></weappiconurl>
+			<pagepath><![CDATA[pages/home/home.html]]></pagepath>
+		</weappinfo>
+	</appmsg>
+	<fromusername>wxid_a</fromusername>
+</msg>`
+
+func TestParseAppMessage_MiniProgram(t *testing.T) {
+	m, err := ParseAppMessage(testMiniProgramXML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Type != AppMsgTypeMiniProgram || m.FromUser != "wxid_a" || m.MiniProgram == nil {
+		t.Fatalf("unexpected app message: %+v", m)
+	}
+	req := m.MiniProgram.ToSendRequest("wxid_b")
+	if req.ToUser != "wxid_b" || req.AppId != "wx23dde3ba32269caa" || req.Type != 2 || req.Version != 92 ||
+		req.PagePath != "pages/home/home.html" || req.ThumbUrl != "3057020100044b30" || req.Title != "肯德基自助点餐" {
+		t.Errorf("unexpected send request: %+v", req)
+	}
+}
+
+func TestParseAppMessage_LinkAndFile(t *testing.T) {
+	m, err := ParseAppMessage(`<msg><appmsg appid=""><title>标题</title><des>描述</des><type>5</type><url>https://example.com</url><sourceusername>gh_a</sourceusername></appmsg></msg>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Link == nil || m.Link.Url != "https://example.com" || m.Link.SourceUserName != "gh_a" {
+		t.Errorf("unexpected link: %+v", m)
+	}
+
+	m, err = ParseAppMessage(`<msg><appmsg><title>a.pdf</title><type>6</type><appattach><totallen>2048</totallen><fileext>pdf</fileext></appattach><md5>m1</md5></appmsg></msg>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.File == nil || m.File.TotalLen != 2048 || m.File.FileExt != "pdf" || m.File.Md5 != "m1" {
+		t.Errorf("unexpected file: %+v", m)
+	}
+
+	if _, err := ParseAppMessage(`<msg></msg>`); err == nil {
+		t.Error("want error for xml without appmsg")
+	}
+}
+
+func TestParseAppMessage_Quote(t *testing.T) {
+	m, err := ParseAppMessage(`<msg><appmsg appid="" sdkver="0"><title>同意</title><type>57</type><refermsg><type>1</type><svrid>7421852369874563210</svrid><fromusr>123@chatroom</fromusr><chatusr>wxid_a</chatusr><displayname>小明</displayname><content>周五聚餐?</content><createtime>1602828061</createtime></refermsg></appmsg></msg>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Quote == nil || m.Quote.Text != "同意" {
+		t.Fatalf("unexpected quote: %+v", m)
+	}
+	r := m.Quote.Refer
+	if r.NewMsgID != 7421852369874563210 || r.ChatUser != "wxid_a" || r.DisplayName != "小明" || r.Content != "周五聚餐?" || r.Type != MsgTypeText {
+		t.Errorf("unexpected refer message: %+v", r)
+	}
+}
@@ -0,0 +1,293 @@
+// Package bridge 把机器人的发送能力包装成本地REST接口
+// 方便CI、告警、定时任务等非Go服务通过机器人往微信里发消息
+//
+//	b := bridge.New(bot)
+//	b.AddCaller(bridge.Caller{Name: "ci", Key: "secret", Allow: []string{"xxx@chatroom"}})
+//	b.AddTemplate("deploy", "{{.service}} 部署{{if .ok}}成功{{else}}失败{{end}}")
+//	log.Fatal(http.ListenAndServe("127.0.0.1:8090", b))
+//
+// 所有接口都是POST,需要在请求头中携带 X-API-Key: {Key}
+//
+//	/v1/text         {"toUser":"","content":"","template":"","data":{},"mentions":[{"userName":"","nickName":""}]}
+//	/v1/pic          {"toUser":"","imgUrl":""}
+//	/v1/voice        {"toUser":"","silkUrl":""}
+//	/v1/video        {"toUser":"","videoUrl":"","videoThumbUrl":""}
+//	/v1/emoji        {"toUser":"","emojiMd5":"","emojiTotalLen":0}
+//	/v1/miniprogram  同chatbot.SendMiniProgramRequest
+package bridge
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"text/template"
+
+	"github.com/chatrbot/chatbot-go"
+)
+
+// Caller 调用方,每个调用方使用自己的key
+type Caller struct {
+	// Name 调用方名称,用于日志
+	Name string
+	// Key 调用方的API Key
+	Key string
+	// Allow 允许发送的接收人,为空时不限制
+	Allow []string
+}
+
+func (c *Caller) allowed(toUser string) bool {
+	if len(c.Allow) == 0 {
+		return true
+	}
+	for _, u := range c.Allow {
+		if u == toUser {
+			return true
+		}
+	}
+	return false
+}
+
+// Mention 文本消息中需要@的人
+type Mention struct {
+	UserName string `json:"userName"` // 微信号
+	NickName string `json:"nickName"` // 群内昵称,会拼接到内容前面
+}
+
+// TextRequest 发送文本的请求
+// content和template二选一,使用template时data作为模板数据
+type TextRequest struct {
+	ToUser   string                 `json:"toUser"`
+	Content  string                 `json:"content"`
+	Template string                 `json:"template"`
+	Data     map[string]interface{} `json:"data"`
+	Mentions []Mention              `json:"mentions"`
+}
+
+// Bridge 本地REST接口服务
+type Bridge struct {
+	bot *chatbot.ChatBot
+	mux *http.ServeMux
+
+	mu        sync.RWMutex
+	callers   map[string]*Caller
+	templates map[string]*template.Template
+}
+
+// New 新建Bridge
+func New(bot *chatbot.ChatBot) *Bridge {
+	b := &Bridge{
+		bot:       bot,
+		mux:       http.NewServeMux(),
+		callers:   make(map[string]*Caller),
+		templates: make(map[string]*template.Template),
+	}
+	b.handle("/v1/text", b.sendText)
+	b.handle("/v1/pic", b.sendPic)
+	b.handle("/v1/voice", b.sendVoice)
+	b.handle("/v1/video", b.sendVideo)
+	b.handle("/v1/emoji", b.sendEmoji)
+	b.handle("/v1/miniprogram", b.sendMiniProgram)
+	return b
+}
+
+// AddCaller 添加调用方,key相同时覆盖
+func (b *Bridge) AddCaller(c Caller) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.callers[c.Key] = &c
+}
+
+// RemoveCaller 移除调用方
+func (b *Bridge) RemoveCaller(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.callers, key)
+}
+
+// AddTemplate 添加文本模板,语法同text/template
+func (b *Bridge) AddTemplate(name, text string) error {
+	t, err := template.New(name).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.templates[name] = t
+	return nil
+}
+
+func (b *Bridge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mux.ServeHTTP(w, r)
+}
+
+// handlerFunc 已经通过鉴权的接口处理函数
+// 返回的toUser用于校验调用方权限
+type handlerFunc func(b *chatbot.ChatBot, body []byte) (toUser string, send func() error, err error)
+
+func (b *Bridge) handle(pattern string, h handlerFunc) {
+	b.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		caller := b.caller(r.Header.Get("X-API-Key"))
+		if caller == nil {
+			writeError(w, http.StatusUnauthorized, errors.New("invalid api key"))
+			return
+		}
+		var buf bytes.Buffer
+		if _, err := buf.ReadFrom(http.MaxBytesReader(w, r.Body, 1<<20)); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		toUser, send, err := h(b.bot.WithContext(r.Context()), buf.Bytes())
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if toUser == "" {
+			writeError(w, http.StatusBadRequest, errors.New("toUser is empty"))
+			return
+		}
+		if !caller.allowed(toUser) {
+			writeError(w, http.StatusForbidden, fmt.Errorf("%s is not allowed to send to %s", caller.Name, toUser))
+			return
+		}
+		if err := send(); err != nil {
+			log.Printf("bridge %s send to %s error:%s\n", caller.Name, toUser, err)
+			writeError(w, http.StatusBadGateway, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+}
+
+// caller 根据key查找调用方
+func (b *Bridge) caller(key string) *Caller {
+	if key == "" {
+		return nil
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for k, c := range b.callers {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			return c
+		}
+	}
+	return nil
+}
+
+func (b *Bridge) sendText(bot *chatbot.ChatBot, body []byte) (string, func() error, error) {
+	var req TextRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return "", nil, err
+	}
+	content, err := b.render(&req)
+	if err != nil {
+		return "", nil, err
+	}
+	content, atList := withMentions(content, req.Mentions)
+	return req.ToUser, func() error {
+		return bot.SendText(req.ToUser, content, atList)
+	}, nil
+}
+
+// render 获取文本内容,指定了模板时使用模板渲染
+func (b *Bridge) render(req *TextRequest) (string, error) {
+	if req.Template == "" {
+		if req.Content == "" {
+			return "", errors.New("content is empty")
+		}
+		return req.Content, nil
+	}
+	b.mu.RLock()
+	t, ok := b.templates[req.Template]
+	b.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("template %s not found", req.Template)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, req.Data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// withMentions 在内容前拼接@昵称
+func withMentions(content string, mentions []Mention) (string, []string) {
+	if len(mentions) == 0 {
+		return content, nil
+	}
+	text := chatbot.Text()
+	for _, m := range mentions {
+		text.Mention(m.UserName, m.NickName)
+	}
+	text.Add(content)
+	return text.Content(), text.AtList()
+}
+
+func (b *Bridge) sendPic(bot *chatbot.ChatBot, body []byte) (string, func() error, error) {
+	var req chatbot.SendPicRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return "", nil, err
+	}
+	return req.ToUser, func() error {
+		return bot.SendPic(req.ToUser, req.ImgUrl)
+	}, nil
+}
+
+func (b *Bridge) sendVoice(bot *chatbot.ChatBot, body []byte) (string, func() error, error) {
+	var req chatbot.SendVoiceRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return "", nil, err
+	}
+	return req.ToUser, func() error {
+		return bot.SendVoice(req.ToUser, req.SilkUrl)
+	}, nil
+}
+
+func (b *Bridge) sendVideo(bot *chatbot.ChatBot, body []byte) (string, func() error, error) {
+	var req chatbot.SendVideoRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return "", nil, err
+	}
+	return req.ToUser, func() error {
+		return bot.SendVideo(req.ToUser, req.VideoUrl, req.VideoThumbUrl)
+	}, nil
+}
+
+func (b *Bridge) sendEmoji(bot *chatbot.ChatBot, body []byte) (string, func() error, error) {
+	var req chatbot.SendEmojiRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return "", nil, err
+	}
+	return req.ToUser, func() error {
+		return bot.SendEmoji(req.ToUser, req.EmojiMd5, strconv.FormatInt(req.EmojiTotalLen, 10))
+	}, nil
+}
+
+func (b *Bridge) sendMiniProgram(bot *chatbot.ChatBot, body []byte) (string, func() error, error) {
+	var req chatbot.SendMiniProgramRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return "", nil, err
+	}
+	return req.ToUser, func() error {
+		return bot.SendMiniProgram(&req)
+	}, nil
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,140 @@
+package bridge
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/chatrbot/chatbot-go"
+	"github.com/gorilla/websocket"
+)
+
+func TestBridge_Render(t *testing.T) {
+	b := New(nil)
+	if err := b.AddTemplate("deploy", "{{.service}} 部署{{if .ok}}成功{{else}}失败{{end}}"); err != nil {
+		t.Fatal(err)
+	}
+	content, err := b.render(&TextRequest{
+		Template: "deploy",
+		Data:     map[string]interface{}{"service": "api", "ok": true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, atList := withMentions(content, []Mention{{UserName: "wxid_a", NickName: "小明"}})
+	if content != "@小明\u2005api 部署成功" {
+		t.Errorf("unexpected content %q", content)
+	}
+	if len(atList) != 1 || atList[0] != "wxid_a" {
+		t.Errorf("unexpected atList %v", atList)
+	}
+}
+
+func TestBridge_Unauthorized(t *testing.T) {
+	b := New(nil)
+	b.AddCaller(Caller{Name: "ci", Key: "secret"})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/text", strings.NewReader(`{}`))
+	req.Header.Set("X-API-Key", "wrong")
+	rec := httptest.NewRecorder()
+	b.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("want 401, got %d", rec.Code)
+	}
+}
+
+// newTestBridge 连接到模拟服务端的Bridge,返回发送到机器人接口的文本请求
+func newTestBridge(t *testing.T) (*Bridge, func() []chatbot.SendTextRequest) {
+	var (
+		mu   sync.Mutex
+		sent []chatbot.SendTextRequest
+	)
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ws" {
+			c, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer c.Close()
+			for {
+				if _, _, err := c.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}
+		var req chatbot.SendTextRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		sent = append(sent, req)
+		mu.Unlock()
+		_, _ = w.Write([]byte(`{"code":0,"data":{}}`))
+	}))
+	t.Cleanup(srv.Close)
+	bot, err := chatbot.New(strings.TrimPrefix(srv.URL, "http://"), "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(bot.Close)
+
+	b := New(bot)
+	b.AddCaller(Caller{Name: "ci", Key: "secret", Allow: []string{"123@chatroom"}})
+	if err := b.AddTemplate("deploy", "{{.service}} 部署{{if .ok}}成功{{else}}失败{{end}}"); err != nil {
+		t.Fatal(err)
+	}
+	return b, func() []chatbot.SendTextRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]chatbot.SendTextRequest(nil), sent...)
+	}
+}
+
+func post(b *Bridge, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("X-API-Key", "secret")
+	rec := httptest.NewRecorder()
+	b.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestBridge_SendText(t *testing.T) {
+	b, sent := newTestBridge(t)
+	rec := post(b, "/v1/text", `{"toUser":"123@chatroom","template":"deploy","data":{"service":"api","ok":true},
+		"mentions":[{"userName":"wxid_a","nickName":"小明"}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("want 200, got %d %s", rec.Code, rec.Body)
+	}
+	reqs := sent()
+	if len(reqs) != 1 {
+		t.Fatalf("want 1 request to bot api, got %d", len(reqs))
+	}
+	req := reqs[0]
+	if req.ToUser != "123@chatroom" || req.Content != "@小明\u2005api 部署成功" || len(req.AtList) != 1 || req.AtList[0] != "wxid_a" {
+		t.Errorf("unexpected request to bot api %+v", req)
+	}
+}
+
+func TestBridge_Rejected(t *testing.T) {
+	b, sent := newTestBridge(t)
+	cases := []struct {
+		body string
+		code int
+	}{
+		// 不在Allow列表中
+		{`{"toUser":"456@chatroom","content":"hi"}`, http.StatusForbidden},
+		// 模板不存在
+		{`{"toUser":"123@chatroom","template":"missing"}`, http.StatusBadRequest},
+		{`{"toUser":"123@chatroom"}`, http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		if rec := post(b, "/v1/text", c.body); rec.Code != c.code {
+			t.Errorf("%s: want %d, got %d %s", c.body, c.code, rec.Code, rec.Body)
+		}
+	}
+	if n := len(sent()); n != 0 {
+		t.Errorf("want nothing sent for rejected requests, got %d", n)
+	}
+}
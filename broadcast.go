@@ -0,0 +1,285 @@
+package chatbot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// defaultBroadcastRate 没有配置限流时Broadcast每秒发送的消息数
+const defaultBroadcastRate = 0.5
+
+// ErrBroadcastCancelled 群发任务被取消,未发送的目标返回该错误
+var ErrBroadcastCancelled = errors.New("broadcast cancelled")
+
+// BroadcastTarget 群发的目标
+type BroadcastTarget struct {
+	UserName string            // 群号或者微信号
+	Name     string            // 群名或者昵称,为空时从Roster中获取
+	Data     map[string]string // 模板中使用的其他字段
+}
+
+// IsGroup 目标是否为群
+func (t BroadcastTarget) IsGroup() bool {
+	return IsGroupMessage(t.UserName)
+}
+
+// BroadcastOptions Broadcast的配置,零值使用默认配置
+type BroadcastOptions struct {
+	// Limiter 任务额外的发送限流,和WithRateLimiter设置的全局限流叠加,两个都满足才会发送
+	// 为空时只使用全局限流,都没有时每2秒一条
+	Limiter *RateLimiter
+	// Roster 用于补全目标的群名或者昵称
+	Roster *Roster
+	// OnResult 每个目标发送完成后回调
+	OnResult func(r BroadcastResult)
+}
+
+// BroadcastResult 单个目标的发送结果
+type BroadcastResult struct {
+	Target   string    `json:"target"`
+	Content  string    `json:"content"`
+	NewMsgId int64     `json:"newMsgId"`
+	Err      error     `json:"-"`
+	Error    string    `json:"error,omitempty"` // Err的描述,用于json格式的报告
+	SentAt   time.Time `json:"sentAt"`
+}
+
+// OK 是否发送成功
+func (r *BroadcastResult) OK() bool {
+	return r.Err == nil
+}
+
+// BroadcastReport 群发报告,Results的顺序和targets一致
+type BroadcastReport struct {
+	Results    []BroadcastResult `json:"results"`
+	Succeeded  int               `json:"succeeded"`
+	Failed     int               `json:"failed"`
+	Cancelled  bool              `json:"cancelled"`
+	StartedAt  time.Time         `json:"startedAt"`
+	FinishedAt time.Time         `json:"finishedAt"`
+}
+
+// Failures 发送失败的结果,包括取消后未发送的目标
+func (r *BroadcastReport) Failures() []BroadcastResult {
+	list := make([]BroadcastResult, 0, r.Failed)
+	for _, res := range r.Results {
+		if !res.OK() {
+			list = append(list, res)
+		}
+	}
+	return list
+}
+
+// BroadcastJob 后台运行的群发任务
+type BroadcastJob struct {
+	bot     *ChatBot
+	tmpl    *template.Template
+	targets []BroadcastTarget
+	opts    BroadcastOptions
+	ctx     context.Context
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	mu     sync.Mutex
+	paused bool
+	resume chan struct{}
+	report BroadcastReport
+	sent   int
+}
+
+// Broadcast 向多个目标群发文本消息
+// message为text/template模板,可以使用{{.Name}}、{{.UserName}}、{{index .Data "key"}}等字段
+// 消息在后台按限流依次发送,通过返回的BroadcastJob暂停、恢复、取消和获取报告
+//
+//	job, _ := bot.Broadcast(targets, "{{.Name}}的各位好,今晚8点停机维护", nil)
+//	report := job.Wait()
+func (bot *ChatBot) Broadcast(targets []BroadcastTarget, message string, opts *BroadcastOptions) (*BroadcastJob, error) {
+	tmpl, err := template.New("broadcast").Option("missingkey=zero").Parse(message)
+	if err != nil {
+		return nil, fmt.Errorf("解析群发模板失败:%w", err)
+	}
+	o := BroadcastOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	// 已经配置了全局限流时发送接口会自动限流,这里不需要重复等待
+	if o.Limiter == nil && bot.bot.limiter == nil {
+		o.Limiter = NewRateLimiter(defaultBroadcastRate, 1)
+	}
+
+	ctx, cancel := context.WithCancel(bot.context())
+	job := &BroadcastJob{
+		bot:     bot.WithContext(ctx),
+		tmpl:    tmpl,
+		targets: targets,
+		opts:    o,
+		ctx:     ctx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		report: BroadcastReport{
+			Results:   make([]BroadcastResult, len(targets)),
+			StartedAt: time.Now(),
+		},
+	}
+	go job.run()
+	return job, nil
+}
+
+func (j *BroadcastJob) run() {
+	defer close(j.done)
+	defer j.cancel()
+	for i, t := range j.targets {
+		res := BroadcastResult{Target: t.UserName}
+		if err := j.wait(); err != nil {
+			j.finish(i, true)
+			return
+		}
+		res.Content, res.Err = j.render(t)
+		if res.Err == nil {
+			var rsp *SendTextResponse
+			rsp, res.Err = j.bot.sendText(t.UserName, res.Content, nil, nil)
+			if res.Err == nil {
+				res.NewMsgId = rsp.NewMsgId
+			}
+		}
+		// 发送过程中取消时不算作发送失败
+		if errors.Is(res.Err, context.Canceled) {
+			j.finish(i, true)
+			return
+		}
+		res.SentAt = time.Now()
+		if res.Err != nil {
+			res.Error = res.Err.Error()
+		}
+		j.mu.Lock()
+		j.report.Results[i] = res
+		j.sent++
+		if res.OK() {
+			j.report.Succeeded++
+		} else {
+			j.report.Failed++
+		}
+		j.mu.Unlock()
+		if j.opts.OnResult != nil {
+			j.opts.OnResult(res)
+		}
+	}
+	j.finish(len(j.targets), false)
+}
+
+// wait 暂停时阻塞到恢复,然后等待限流
+func (j *BroadcastJob) wait() error {
+	for {
+		j.mu.Lock()
+		paused, resume := j.paused, j.resume
+		j.mu.Unlock()
+		if !paused {
+			break
+		}
+		select {
+		case <-j.ctx.Done():
+			return j.ctx.Err()
+		case <-resume:
+		}
+	}
+	if j.opts.Limiter != nil {
+		return j.opts.Limiter.Wait(j.ctx)
+	}
+	return j.ctx.Err()
+}
+
+// finish 把from之后未发送的目标标记为取消
+func (j *BroadcastJob) finish(from int, cancelled bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for i := from; i < len(j.targets); i++ {
+		j.report.Results[i] = BroadcastResult{
+			Target: j.targets[i].UserName,
+			Err:    ErrBroadcastCancelled,
+			Error:  ErrBroadcastCancelled.Error(),
+		}
+		j.report.Failed++
+	}
+	j.report.Cancelled = cancelled
+	j.report.FinishedAt = time.Now()
+}
+
+// render 渲染单个目标的消息内容
+func (j *BroadcastJob) render(t BroadcastTarget) (string, error) {
+	if t.Name == "" && j.opts.Roster != nil {
+		if t.IsGroup() {
+			t.Name = j.opts.Roster.GroupName(t.UserName)
+		} else if c, ok := j.opts.Roster.Lookup(t.UserName); ok {
+			t.Name = c.NickName
+		}
+	}
+	var sb strings.Builder
+	if err := j.tmpl.Execute(&sb, t); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// Pause 暂停发送,正在发送的消息不受影响
+func (j *BroadcastJob) Pause() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if !j.paused {
+		j.paused = true
+		j.resume = make(chan struct{})
+	}
+}
+
+// Resume 恢复发送
+func (j *BroadcastJob) Resume() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.paused {
+		j.paused = false
+		close(j.resume)
+	}
+}
+
+// Paused 是否已暂停
+func (j *BroadcastJob) Paused() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.paused
+}
+
+// Cancel 取消任务,未发送的目标在报告中标记为ErrBroadcastCancelled
+func (j *BroadcastJob) Cancel() {
+	j.cancel()
+}
+
+// Progress 已处理的目标数和总数
+func (j *BroadcastJob) Progress() (sent, total int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.sent, len(j.targets)
+}
+
+// Done 任务结束时关闭
+func (j *BroadcastJob) Done() <-chan struct{} {
+	return j.done
+}
+
+// Wait 等待任务结束并返回报告
+func (j *BroadcastJob) Wait() *BroadcastReport {
+	<-j.done
+	return j.Report()
+}
+
+// Report 当前的报告,任务未结束时只包含已发送的目标
+func (j *BroadcastJob) Report() *BroadcastReport {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	r := j.report
+	r.Results = append([]BroadcastResult(nil), j.report.Results...)
+	return &r
+}
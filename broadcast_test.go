@@ -0,0 +1,129 @@
+package chatbot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBroadcast(t *testing.T) {
+	var id int64
+	bot := newTestBot(t, func(w http.ResponseWriter, r *http.Request) {
+		req := &SendTextRequest{}
+		_ = json.NewDecoder(r.Body).Decode(req)
+		if req.ToUser == "bad@chatroom" {
+			_, _ = w.Write([]byte(`{"code":1,"msg":"send failed"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"code":0,"data":{"newMsgId":` + strconv.FormatInt(atomic.AddInt64(&id, 1), 10) + `}}`))
+	})
+	roster, _ := NewRoster(nil)
+	_ = roster.ObserveEvent(&GroupBotEvent{Event: GroupEventInvited, Group: GroupBase{GroupUserName: "b@chatroom", GroupNickName: "B群"}})
+
+	targets := []BroadcastTarget{
+		{UserName: "a@chatroom", Name: "A群"},
+		{UserName: "bad@chatroom"},
+		{UserName: "b@chatroom"},
+	}
+	job, err := bot.Broadcast(targets, "{{.Name}}的各位好", &BroadcastOptions{
+		Limiter: NewRateLimiter(1000, 10),
+		Roster:  roster,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := job.Wait()
+	if report.Succeeded != 2 || report.Failed != 1 || report.Cancelled {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if report.Results[0].Content != "A群的各位好" || report.Results[0].NewMsgId != 1 {
+		t.Errorf("unexpected result: %+v", report.Results[0])
+	}
+	if report.Results[2].Content != "B群的各位好" || report.Results[2].NewMsgId != 2 {
+		t.Errorf("want name from roster, got %+v", report.Results[2])
+	}
+	if f := report.Failures(); len(f) != 1 || f[0].Target != "bad@chatroom" {
+		t.Errorf("unexpected failures: %+v", f)
+	}
+	data, _ := json.Marshal(report)
+	if !strings.Contains(string(data), `"error":"`) {
+		t.Errorf("want failure reason in json report, got %s", data)
+	}
+
+	if _, err := bot.Broadcast(targets, "{{.Name", nil); err == nil {
+		t.Error("want template error")
+	}
+}
+
+func TestBroadcast_PauseCancel(t *testing.T) {
+	bot := newTestBot(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"code":0,"data":{"newMsgId":1}}`))
+	})
+	targets := []BroadcastTarget{{UserName: "a"}, {UserName: "b"}, {UserName: "c"}}
+	sent := make(chan struct{}, len(targets))
+	job, _ := bot.Broadcast(targets, "hi", &BroadcastOptions{
+		Limiter:  NewRateLimiter(1000, 10),
+		OnResult: func(BroadcastResult) { sent <- struct{}{} },
+	})
+	job.Pause()
+	select {
+	case <-sent:
+		// 暂停前可能已经发出了第一条
+	case <-time.After(50 * time.Millisecond):
+	}
+	n, _ := job.Progress()
+	time.Sleep(50 * time.Millisecond)
+	if m, _ := job.Progress(); m != n || !job.Paused() {
+		t.Fatalf("want paused job, progress %d -> %d", n, m)
+	}
+	job.Cancel()
+	report := job.Wait()
+	if !report.Cancelled || report.Succeeded != n || report.Failed != len(targets)-n {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	if report.Results[len(targets)-1].Err != ErrBroadcastCancelled {
+		t.Errorf("want cancelled result, got %+v", report.Results[len(targets)-1])
+	}
+}
+
+func TestBroadcast_GlobalLimiter(t *testing.T) {
+	var sent int32
+	bot := newTestBot(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&sent, 1)
+		_, _ = w.Write([]byte(`{"code":0,"data":{"newMsgId":1}}`))
+	})
+	// 任务的Limiter不能绕过全局限流
+	bot.bot.limiter = NewRateLimiter(0.001, 1)
+	job, err := bot.Broadcast([]BroadcastTarget{{UserName: "a@chatroom"}, {UserName: "b@chatroom"}}, "通知",
+		&BroadcastOptions{Limiter: NewRateLimiter(1000, 10)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if n := atomic.LoadInt32(&sent); n != 1 {
+		t.Errorf("want second send held by the global limiter, got %d sends", n)
+	}
+	job.Cancel()
+	if report := job.Wait(); !report.Cancelled || report.Succeeded != 1 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	l := NewRateLimiter(100, 2)
+	if !l.Allow() || !l.Allow() || l.Allow() {
+		t.Error("want burst of 2")
+	}
+	start := time.Now()
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) < 5*time.Millisecond {
+		t.Error("want wait for next token")
+	}
+}
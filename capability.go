@@ -0,0 +1,89 @@
+package chatbot
+
+import "sync"
+
+// Feature 服务端的可选能力
+// 对应的接口没有列在公开的接口文档(https://github.com/chatrbot/chatbot)中,
+// 不同版本的服务端不一定提供,第一次调用时服务端返回404则认为不支持,
+// 之后直接返回ErrUnsupported而不再请求
+type Feature string
+
+const (
+	FeatureQuote      Feature = "quote"      // 引用回复
+	FeatureLink       Feature = "link"       // 链接卡片
+	FeatureCard       Feature = "card"       // 名片
+	FeatureLocation   Feature = "location"   // 位置
+	FeatureGroupAdmin Feature = "groupAdmin" // 群管理,删除群成员以外的接口
+)
+
+// optionalAPIs 可选接口和对应的能力
+var optionalAPIs = map[string]Feature{
+	urlSendQuote:      FeatureQuote,
+	urlSendLink:       FeatureLink,
+	urlSendCard:       FeatureCard,
+	urlSendLocation:   FeatureLocation,
+	urlAddGroupMember: FeatureGroupAdmin,
+	urlInviteGroup:    FeatureGroupAdmin,
+	urlGroupMembers:   FeatureGroupAdmin,
+	urlSetGroupName:   FeatureGroupAdmin,
+	urlSetGroupNotice: FeatureGroupAdmin,
+	urlTransferOwner:  FeatureGroupAdmin,
+	urlAddGroupAdmin:  FeatureGroupAdmin,
+	urlDelGroupAdmin:  FeatureGroupAdmin,
+}
+
+// capabilities 运行时探测到的不支持的可选接口
+type capabilities struct {
+	mu          sync.RWMutex
+	unsupported map[string]bool
+}
+
+// check 可选接口已知不支持时返回UnsupportedError
+func (c *capabilities) check(addr string) error {
+	feature, ok := optionalAPIs[addr]
+	if !ok {
+		return nil
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.unsupported[addr] {
+		return &UnsupportedError{Feature: feature, Endpoint: addr}
+	}
+	return nil
+}
+
+// observe 可选接口返回404时记录为不支持并返回UnsupportedError,其他情况原样返回err
+func (c *capabilities) observe(addr string, err error) error {
+	feature, ok := optionalAPIs[addr]
+	if !ok {
+		return err
+	}
+	if e, isStatus := err.(*StatusError); !isStatus || e.StatusCode != 404 {
+		return err
+	}
+	c.mu.Lock()
+	if c.unsupported == nil {
+		c.unsupported = make(map[string]bool)
+	}
+	c.unsupported[addr] = true
+	c.mu.Unlock()
+	return &UnsupportedError{Feature: feature, Endpoint: addr, Err: err}
+}
+
+// supports 能力下的接口都没有被探测为不支持
+func (c *capabilities) supports(f Feature) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for addr, feature := range optionalAPIs {
+		if feature == f && c.unsupported[addr] {
+			return false
+		}
+	}
+	return true
+}
+
+// Supports 服务端是否支持该能力
+// 只有调用过对应接口后才能确定,未调用过时返回true
+func (bot *ChatBot) Supports(f Feature) bool {
+	return bot.bot.caps.supports(f)
+}
@@ -1,10 +1,14 @@
 package chatbot
 
 import (
+	"context"
 	"errors"
+	"io/ioutil"
+	"net/http"
 	"strconv"
+	"time"
 
-	"github.com/beevik/etree"
+	"github.com/chatrbot/chatbot-go/silk"
 )
 
 // 机器人实例
@@ -17,21 +21,45 @@ type ChatBot struct {
 	bot *BotServer
 	// ws连接实例
 	ws *WsServer
+	// 调用接口时使用的上下文
+	ctx context.Context
+	// 发送本地数据使用的临时文件服务
+	files *FileServer
+	// 媒体文件缓存
+	media *MediaCache
+	// WAV转silk的编码器
+	voice VoiceEncoder
+	// 视频封面
+	thumbs       ThumbnailProvider
+	defaultThumb string
+	// 下载媒体文件使用的http客户端
+	client *http.Client
 }
 
 // New 新建一个ChatBot实例
 // @host WebSocket的服务端地址
 // @token激活后机器人给的token
-func New(host, token string) (*ChatBot, error) {
-	ws, err := newWSClient(host, token)
+// @opts 可选配置,例如WithMetrics
+func New(host, token string, opts ...Option) (*ChatBot, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+	ws, err := newWSClient(host, token, o)
 	if err != nil {
 		return nil, err
 	}
 	return &ChatBot{
-		token: token,
-		host:  host,
-		ws:    ws,
-		bot:   newBotServer(host, token),
+		token:        token,
+		host:         host,
+		ws:           ws,
+		bot:          newBotServer(host, token, o),
+		files:        o.fileServer,
+		media:        o.mediaCache,
+		voice:        o.voiceEncoder,
+		thumbs:       o.thumbnailProvider,
+		defaultThumb: o.defaultThumb,
+		client:       o.httpClient,
 	}, nil
 }
 
@@ -39,6 +67,21 @@ func (bot *ChatBot) Close() {
 	bot.ws.Close()
 }
 
+// WithContext 返回一个绑定了ctx的浅拷贝,之后通过它调用的接口都会使用这个ctx
+// 在插件中传入msg.Context()即可把发送的消息关联到收到的消息的追踪上
+func (bot *ChatBot) WithContext(ctx context.Context) *ChatBot {
+	b := *bot
+	b.ctx = ctx
+	return &b
+}
+
+func (bot *ChatBot) context() context.Context {
+	if bot.ctx != nil {
+		return bot.ctx
+	}
+	return context.Background()
+}
+
 // Run 连接WebSocket服务并且开始监听
 func (bot *ChatBot) Run() {
 	bot.ws.ReceiveCallbackMessage()
@@ -49,56 +92,180 @@ func (bot *ChatBot) Use(plugin ...Plugin) {
 	bot.ws.addPlugin(plugin...)
 }
 
+// Connected WebSocket当前是否已连接
+func (bot *ChatBot) Connected() bool {
+	return bot.ws.connected()
+}
+
+// LastPong 最后一次收到服务端心跳回复的时间,还没收到过时为零值
+func (bot *ChatBot) LastPong() time.Time {
+	return bot.ws.lastPongTime()
+}
+
+// Plugins 已加载的插件及其启用状态
+func (bot *ChatBot) Plugins() []PluginState {
+	return bot.ws.pluginStates()
+}
+
+// SetPluginEnabled 启用或者停用插件,停用的插件不会再收到消息
+func (bot *ChatBot) SetPluginEnabled(name string, enabled bool) error {
+	return bot.ws.setPluginEnabled(name, enabled)
+}
+
 // SendText 发送文本形式的消息
 // @toUser 接收人微信号,一般为机器人推送过来的消息发送人,即你自己
 // @content 文本内容,如果有人被@需要填写对方昵称
 // @atList 被@人列表,这里填写的是对方微信号
+// 每个被@的人在内容中都需要有对应的@昵称,昵称后面可以是\u2005或者普通空格,否则返回ErrMentionMismatch
+// 推荐使用SendTextMessage配合Text()构造带@的消息
 func (bot *ChatBot) SendText(toUser, content string, atList []string) error {
+	_, err := bot.sendText(toUser, content, atList, nil)
+	return err
+}
+
+// sendText 发送文本消息并返回接口响应,用于需要NewMsgId的场景
+// @nicknames 被@人的昵称,用于校验atList,可以为nil
+func (bot *ChatBot) sendText(toUser, content string, atList []string, nicknames map[string]string) (*SendTextResponse, error) {
 	// 个人消息不存在@
 	if !IsGroupMessage(toUser) {
 		atList = nil
 	}
-	_, err := bot.bot.sendTextMessage(&SendTextRequest{
+	if err := checkMentions(content, atList, nicknames); err != nil {
+		return nil, err
+	}
+	return bot.bot.sendTextMessage(bot.context(), &SendTextRequest{
 		ToUser:  toUser,
 		AtList:  atList,
 		Content: content,
 	})
+}
+
+// SendTextMessage 发送使用Text()构造的文本消息
+func (bot *ChatBot) SendTextMessage(toUser string, text *TextBuilder) error {
+	_, err := bot.sendText(toUser, text.Content(), text.AtList(), text.nicknames)
 	return err
 }
 
+// ReplyTo 引用msg回复文本消息
+// 服务端不支持引用回复时降级为普通消息,群消息会@原发言人
+func (bot *ChatBot) ReplyTo(msg *UserMessage, text string) error {
+	_, err := bot.bot.sendQuoteMessage(bot.context(), &SendQuoteRequest{
+		ToUser:   msg.FromUser,
+		NewMsgId: msg.NewMsgID,
+		Content:  text,
+	})
+	if !errors.Is(err, ErrUnsupported) {
+		return err
+	}
+	if !IsGroupMessage(msg.FromUser) || msg.GroupMember == "" {
+		return bot.SendText(msg.FromUser, text, nil)
+	}
+	nickname := msg.GroupMemberNickname
+	if nickname == "" {
+		nickname = msg.WhoAtBot
+	}
+	return bot.SendTextMessage(msg.FromUser, Text().Mention(msg.GroupMember, nickname).Add(text))
+}
+
 // SendPic 发送图片消息
 // @toUser 接收人微信号
 // @imgUrl 图片的网络地址
 func (bot *ChatBot) SendPic(toUser, imgUrl string) error {
-	_, err := bot.bot.sendPicMessage(&SendPicRequest{
+	_, err := bot.bot.sendPicMessage(bot.context(), &SendPicRequest{
 		ToUser: toUser,
 		ImgUrl: imgUrl,
 	})
 	return err
 }
 
+// SendPicBytes 发送内存中的图片,需要配置WithFileServer
+// @name 文件名,用于确定图片格式,例如chart.png
+func (bot *ChatBot) SendPicBytes(toUser, name string, data []byte) error {
+	if bot.files == nil {
+		return ErrNoFileServer
+	}
+	return bot.SendPic(toUser, bot.files.AddBytes(name, data))
+}
+
+// SendPicFile 发送本地图片文件,需要配置WithFileServer
+func (bot *ChatBot) SendPicFile(toUser, path string) error {
+	if bot.files == nil {
+		return ErrNoFileServer
+	}
+	u, err := bot.files.AddFile(path)
+	if err != nil {
+		return err
+	}
+	return bot.SendPic(toUser, u)
+}
+
 // SendVoice 发送语音
 // @toUser 接收人微信号
-// @url 音频文件网络地址
+// @url 音频文件网络地址,需要为silk格式
+// 配置了WithVoiceEncoder和WithFileServer时也可以是.wav结尾的地址,会自动转为silk
 func (bot *ChatBot) SendVoice(toUser, url string) error {
-	_, err := bot.bot.sendVoiceMessage(&SendVoiceRequest{
+	if bot.voice != nil && isWAVUrl(url) {
+		silkUrl, err := bot.wavUrlToSilk(url)
+		if err != nil {
+			return err
+		}
+		url = silkUrl
+	}
+	_, err := bot.bot.sendVoiceMessage(bot.context(), &SendVoiceRequest{
 		ToUser:  toUser,
 		SilkUrl: url,
 	})
 	return err
 }
 
+// SendVoiceBytes 发送内存中的silk或者WAV语音,需要配置WithFileServer
+// WAV需要配置WithVoiceEncoder
+func (bot *ChatBot) SendVoiceBytes(toUser string, data []byte) error {
+	if bot.files == nil {
+		return ErrNoFileServer
+	}
+	voice, err := bot.toSilk(data)
+	if err != nil {
+		return err
+	}
+	return bot.SendVoice(toUser, bot.files.AddBytes("voice.silk", voice))
+}
+
+// SendVoiceFile 发送本地silk或者WAV语音文件,需要配置WithFileServer
+// WAV需要配置WithVoiceEncoder
+func (bot *ChatBot) SendVoiceFile(toUser, path string) error {
+	if bot.files == nil {
+		return ErrNoFileServer
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if silk.IsWAV(data) {
+		return bot.SendVoiceBytes(toUser, data)
+	}
+	u, err := bot.files.AddFile(path)
+	if err != nil {
+		return err
+	}
+	return bot.SendVoice(toUser, u)
+}
+
 // SendVideo 发送视频
 // @toUser 接收人微信号
 // @videoUrl 视频网络地址
 // @thumbUrl 封面缩略图地址
-// 视频发送必须有封面图,如果需要根据视频内容截取封面
-// 可以自行搜索ffmpeg相关的资料
+// 视频发送必须有封面图,thumbUrl为空时依次使用WithThumbnailProvider、WithDefaultThumb,
+// 都没有配置时在配置了WithFileServer的情况下生成一张占位封面
 func (bot *ChatBot) SendVideo(toUser, videoUrl, thumbUrl string) error {
 	if thumbUrl == "" {
-		return errors.New("thumbUrl is empty")
+		u, err := bot.thumbnail(videoUrl, nil)
+		if err != nil {
+			return err
+		}
+		thumbUrl = u
 	}
-	_, err := bot.bot.sendVideoMessage(&SendVideoRequest{
+	_, err := bot.bot.sendVideoMessage(bot.context(), &SendVideoRequest{
 		ToUser:        toUser,
 		VideoUrl:      videoUrl,
 		VideoThumbUrl: thumbUrl,
@@ -106,6 +273,38 @@ func (bot *ChatBot) SendVideo(toUser, videoUrl, thumbUrl string) error {
 	return err
 }
 
+// SendVideoBytes 发送内存中的视频和封面,需要配置WithFileServer
+// thumb为空时和SendVideo一样自动处理封面
+func (bot *ChatBot) SendVideoBytes(toUser string, video, thumb []byte) error {
+	if bot.files == nil {
+		return ErrNoFileServer
+	}
+	thumbUrl := ""
+	if len(thumb) > 0 {
+		thumbUrl = bot.files.AddBytes("thumb.jpg", thumb)
+	}
+	return bot.SendVideo(toUser, bot.files.AddBytes("video.mp4", video), thumbUrl)
+}
+
+// SendVideoFile 发送本地视频文件和封面图片文件,需要配置WithFileServer
+// thumbPath为空时和SendVideo一样自动处理封面
+func (bot *ChatBot) SendVideoFile(toUser, videoPath, thumbPath string) error {
+	if bot.files == nil {
+		return ErrNoFileServer
+	}
+	videoUrl, err := bot.files.AddFile(videoPath)
+	if err != nil {
+		return err
+	}
+	thumbUrl := ""
+	if thumbPath != "" {
+		if thumbUrl, err = bot.files.AddFile(thumbPath); err != nil {
+			return err
+		}
+	}
+	return bot.SendVideo(toUser, videoUrl, thumbUrl)
+}
+
 // SendEmoji 发送表情动图
 // toUser 接收人微信号
 // emojiMd5 从收到的xml中可以解析md5字段
@@ -115,7 +314,7 @@ func (bot *ChatBot) SendEmoji(toUser, emojiMd5, emojiLen string) error {
 	if err != nil {
 		return err
 	}
-	_, err = bot.bot.sendEmojiMessage(&SendEmojiRequest{
+	_, err = bot.bot.sendEmojiMessage(bot.context(), &SendEmojiRequest{
 		ToUser:        toUser,
 		EmojiTotalLen: l,
 		EmojiMd5:      emojiMd5,
@@ -123,6 +322,20 @@ func (bot *ChatBot) SendEmoji(toUser, emojiMd5, emojiLen string) error {
 	return err
 }
 
+// SendGif 上传并发送动图
+// @gifUrl 动图的网络地址
+// 返回的md5和length可以保存下来,之后用SendEmoji直接发送不需要再次上传
+func (bot *ChatBot) SendGif(toUser, gifUrl string) (md5 string, length int64, err error) {
+	rsp, err := bot.bot.sendEmojiMessage(bot.context(), &SendEmojiRequest{
+		ToUser: toUser,
+		GifUrl: gifUrl,
+	})
+	if err != nil {
+		return "", 0, err
+	}
+	return rsp.Md5, rsp.TotalLen, nil
+}
+
 // SendMiniProgram 发送小程序
 // toUser	接收人微信号/ID
 // thumbUrl	缩略图地址
@@ -138,57 +351,86 @@ func (bot *ChatBot) SendEmoji(toUser, emojiMd5, emojiLen string) error {
 // iconUrl 图标地址
 // pagePath 启动页
 func (bot *ChatBot) SendMiniProgram(req *SendMiniProgramRequest) error {
-	_, err := bot.bot.sendMiniProgramMessage(req)
+	_, err := bot.bot.sendMiniProgramMessage(bot.context(), req)
+	return err
+}
+
+// SendLink 发送链接卡片
+// 服务端不支持时返回ErrUnsupported,参考FeatureLink
+func (bot *ChatBot) SendLink(req *SendLinkRequest) error {
+	_, err := bot.bot.sendLinkMessage(bot.context(), req)
+	return err
+}
+
+// SendCard 发送名片,服务端不支持时返回ErrUnsupported
+// @toUser 接收人微信号
+// @cardUser 名片的微信号
+// @nickname 名片显示的昵称
+func (bot *ChatBot) SendCard(toUser, cardUser, nickname string) error {
+	_, err := bot.bot.sendCardMessage(bot.context(), &SendCardRequest{
+		ToUser:   toUser,
+		CardUser: cardUser,
+		NickName: nickname,
+	})
+	return err
+}
+
+// SendLocation 发送位置,服务端不支持时返回ErrUnsupported
+func (bot *ChatBot) SendLocation(req *SendLocationRequest) error {
+	_, err := bot.bot.sendLocationMessage(bot.context(), req)
 	return err
 }
 
 // DownloadPic 下载图片
 func (bot *ChatBot) DownloadPic(xml string) (*DownloadImageResponse, error) {
-	return bot.bot.downloadPic(&DownloadImageRequest{XML: xml})
+	return bot.bot.downloadPic(bot.context(), &DownloadImageRequest{XML: xml})
 }
 
 // DownloadVideo 下载视频
 func (bot *ChatBot) DownloadVideo(xml string) (*DownloadVideoResponse, error) {
-	return bot.bot.downloadVideo(&DownloadVideoRequest{XML: xml})
+	return bot.bot.downloadVideo(bot.context(), &DownloadVideoRequest{XML: xml})
 }
 
 // DownloadVoice 下载音频
 func (bot *ChatBot) DownloadVoice(msgID int64, xml string) (*DownloadVoiceResponse, error) {
-	return bot.bot.downloadVoice(&DownloadVoiceRequest{NewMsgId: msgID, XML: xml})
+	return bot.bot.downloadVoice(bot.context(), &DownloadVoiceRequest{NewMsgId: msgID, XML: xml})
 }
 
 // DownloadEmoji 下载表情或者动态图片
 // 注意这个方法只能提取表情的下载地址不能用于直接发送
 // 发送(转发)表情需要用拿到的xml中的md5和len字段发送,可以使用ParseEmojiXML方法来获取
 func (bot *ChatBot) DownloadEmoji(xml string) (string, error) {
-	doc := etree.NewDocument()
-	if err := doc.ReadFromString(xml); err != nil {
+	info, err := ParseEmojiXML(xml)
+	if err != nil {
 		return "", err
 	}
-	url := doc.FindElement("//emoji").SelectAttr("cdnurl").Value
-	return url, nil
+	if info.CdnUrl == "" {
+		return "", errors.New("emoji cdnurl is empty")
+	}
+	return info.CdnUrl, nil
 }
 
 // ParseEmojiXML 解析emoji表情中的md5和len字段
 func (bot *ChatBot) ParseEmojiXML(xml string) (md5, length string, err error) {
-	doc := etree.NewDocument()
-	if err := doc.ReadFromString(xml); err != nil {
+	info, err := ParseEmojiXML(xml)
+	if err != nil {
 		return "", "", err
 	}
-	e := doc.FindElement("//emoji")
-	md5 = e.SelectAttr("md5").Value
-	length = e.SelectAttr("len").Value
-	return
+	if info.Md5 == "" {
+		return "", "", ErrEmptyEmojiMd5
+	}
+	return info.Md5, strconv.FormatInt(info.Len, 10), nil
 }
 
 // DelGroupMembers 删除群成员
+// 机器人不是群主或者管理员时返回的错误满足errors.Is(err, ErrPermissionDenied)
 func (bot *ChatBot) DelGroupMembers(group string, members []string) ([]string, error) {
-	rsp, err := bot.bot.delGroupMembers(&DelGroupRequest{
+	rsp, err := bot.bot.delGroupMembers(bot.context(), &DelGroupRequest{
 		Group:      group,
 		MemberList: members,
 	})
 	if err != nil {
-		return nil, err
+		return nil, bot.groupError("delChatRoomMember", group, err)
 	}
 	return rsp.DelMemberList, nil
 }
@@ -16,7 +16,7 @@ func TestMain(t *testing.M) {
 	bot = &ChatBot{
 		token: token,
 		host:  host,
-		bot:   newBotServer(host, token),
+		bot:   newBotServer(host, token, defaultOptions()),
 	}
 	t.Run()
 }
@@ -0,0 +1,53 @@
+package chatbot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skip("tzdata not available")
+	}
+	// 2026-10-16是周五
+	from := time.Date(2026, 10, 16, 10, 0, 0, 0, shanghai)
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"30 9 * * 1-5", time.Date(2026, 10, 19, 9, 30, 0, 0, shanghai)},
+		{"*/15 * * * *", time.Date(2026, 10, 16, 10, 15, 0, 0, shanghai)},
+		{"0 18 * * 5", time.Date(2026, 10, 16, 18, 0, 0, 0, shanghai)},
+		{"0 0 1 * *", time.Date(2026, 11, 1, 0, 0, 0, 0, shanghai)},
+		{"0 9 * * 7", time.Date(2026, 10, 18, 9, 0, 0, 0, shanghai)},
+		{"@daily", time.Date(2026, 10, 17, 0, 0, 0, 0, shanghai)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, shanghai)},
+		// 日和星期都有限制时满足其一即可
+		{"0 12 20 * 1", time.Date(2026, 10, 19, 12, 0, 0, 0, shanghai)},
+	}
+	for _, tt := range tests {
+		c, err := ParseCron(tt.spec, shanghai)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.spec, err)
+		}
+		if got := c.Next(from); !got.Equal(tt.want) {
+			t.Errorf("%s: want %s, got %s", tt.spec, tt.want, got)
+		}
+	}
+
+	// CRON_TZ前缀覆盖默认时区
+	c, err := ParseCron("CRON_TZ=Asia/Shanghai 0 9 * * *", time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Next(time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)); !got.Equal(time.Date(2026, 10, 16, 1, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected next in Asia/Shanghai: %s", got.UTC())
+	}
+
+	for _, spec := range []string{"* * * *", "60 * * * *", "5-1 * * * *", "*/0 * * * *", "CRON_TZ=Mars/Base * * * * *"} {
+		if _, err := ParseCron(spec, nil); err == nil {
+			t.Errorf("%s: want error", spec)
+		}
+	}
+}
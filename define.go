@@ -1,6 +1,10 @@
 package chatbot
 
-import "encoding/json"
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+)
 
 type PushMsgType int
 
@@ -22,16 +26,32 @@ const (
 type PushMessage struct {
 	MsgType PushMsgType     `json:"msgType"`
 	Data    json.RawMessage `json:"data"`
+
+	ctx context.Context
+}
+
+// Context 处理这条消息时的上下文,开启追踪时携带了当前插件的span
+// 配合ChatBot.WithContext使用,可以把插件内调用的接口关联到这条消息上
+func (m *PushMessage) Context() context.Context {
+	if m.ctx != nil {
+		return m.ctx
+	}
+	return context.Background()
 }
 
 // 收到的转发消息具体分类
 const (
-	MsgTypeText   = 1  // 文本消息
-	MsgTypeImg    = 3  // 图片消息
-	MsgTypeVoice  = 34 // 语音消息
-	MsgTypeVideo  = 43 // 视频消息
-	MsgTypeEmoji  = 47 // 表情动图消息
-	MsgTypeApplet = 49 // 小程序
+	MsgTypeText     = 1  // 文本消息
+	MsgTypeImg      = 3  // 图片消息
+	MsgTypeVoice    = 34 // 语音消息
+	MsgTypeCard     = 42 // 名片消息
+	MsgTypeVideo    = 43 // 视频消息
+	MsgTypeEmoji    = 47 // 表情动图消息
+	MsgTypeLocation = 48 // 位置消息
+	MsgTypeApplet   = 49 // 小程序
+
+	MsgTypeSys       = 10000 // 系统提示,例如邀请进群、红包提示
+	MsgTypeSysNotice = 10002 // 系统通知,内容为sysmsg xml,包括撤回和拍一拍
 )
 
 const (
@@ -59,6 +79,8 @@ type UserMessage struct {
 	GroupMemberNickname string `json:"groupMemberNickname"` // 群内说话人的微信昵称
 	GroupMemberRole     int8   `json:"groupMemberRole"`     // 用户在群内身份,1成员,2管理员,3群主
 	GroupContent        string `json:"groupContent"`        // 如果是群消息,则为分离content后的群消息内容
+
+	source *MsgSource
 }
 
 func (m *UserMessage) IsAdmin() bool {
@@ -69,29 +91,110 @@ func (m *UserMessage) IsGroupOwner() bool {
 	return m.GroupMemberRole == RoleOwner
 }
 
+// Source 解析后的MsgSource,解析失败时返回空的MsgSource
+func (m *UserMessage) Source() *MsgSource {
+	if m.source == nil {
+		s, err := ParseMsgSource(m.MsgSource)
+		if err != nil {
+			s = &MsgSource{}
+		}
+		m.source = s
+	}
+	return m.source
+}
+
+// IsAtAll 是否为@所有人的消息
+func (m *UserMessage) IsAtAll() bool {
+	for _, u := range m.AtList {
+		if u == AtAllUser {
+			return true
+		}
+	}
+	return m.Source().AtAll()
+}
+
+// MemberCount 群成员数量,私聊消息或者未知时为0
+func (m *UserMessage) MemberCount() int {
+	return m.Source().MemberCount
+}
+
+// IsSilenced 机器人是否对这个群开启了消息免打扰
+func (m *UserMessage) IsSilenced() bool {
+	return m.Source().Silence
+}
+
 type GroupEvent int
 
+// 新增的事件追加在最后,保持已有事件的值不变
 const (
-	// 机器人被邀请进群
+	// 机器人被邀请进群,Operator为邀请人
 	GroupEventInvited GroupEvent = 100000 + iota
-	// 机器人被踢出群
+	// 机器人被踢出群,Operator为操作人
 	GroupEventKicked
-	// 群内有新用户加群
+	// 群内有新用户加群,Operator为邀请人,扫码进群时为空
 	GroupEventNewMember
-	// 群内有用户离开
+	// 群内有用户离开,Operator不为空时表示被踢出
 	GroupEventMemberQuit
+	// 群名称被修改,Before和After为修改前后的群名
+	GroupEventRenamed
+	// 设置了群管理员,Members为新的管理员
+	GroupEventAdminAdded
+	// 取消了群管理员,Members为被取消的管理员
+	GroupEventAdminRemoved
+	// 群公告被修改,Before和After为修改前后的公告
+	GroupEventAnnouncement
+	// 群主转让,Operator为原群主,Members为新群主
+	GroupEventOwnerChanged
 )
 
+var groupEventNames = map[GroupEvent]string{
+	GroupEventInvited:      "invited",
+	GroupEventKicked:       "kicked",
+	GroupEventNewMember:    "new_member",
+	GroupEventMemberQuit:   "member_quit",
+	GroupEventRenamed:      "renamed",
+	GroupEventAdminAdded:   "admin_added",
+	GroupEventAdminRemoved: "admin_removed",
+	GroupEventAnnouncement: "announcement",
+	GroupEventOwnerChanged: "owner_changed",
+}
+
+func (e GroupEvent) String() string {
+	if name, ok := groupEventNames[e]; ok {
+		return name
+	}
+	return "unknown(" + strconv.Itoa(int(e)) + ")"
+}
+
 // 接收到的群内事件
+// 旧版本服务端只推送event、group和members,其余字段为空
 type GroupBotEvent struct {
 	// 事件id
 	Event GroupEvent `json:"event"`
 	// 事件中文提示
-	EventText string `form:"eventText"`
+	EventText string `json:"eventText"`
 	// 群信息
 	Group GroupBase `json:"group"`
-	// 变动的群成员
+	// 变动的群成员,即事件的目标成员
 	Members []MemberBase `json:"members"`
+	// 操作人,例如邀请人、踢人的管理员,未知时为nil
+	Operator *MemberBase `json:"operator,omitempty"`
+	// 修改前后的值,用于群名称和群公告
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+// Target 第一个目标成员,没有时为nil
+func (e *GroupBotEvent) Target() *MemberBase {
+	if len(e.Members) == 0 {
+		return nil
+	}
+	return &e.Members[0]
+}
+
+// ByOperator 事件是否由群内其他成员操作,例如被邀请进群而不是扫码进群
+func (e *GroupBotEvent) ByOperator() bool {
+	return e.Operator != nil && e.Operator.UserName != ""
 }
 
 // 群基本信息
@@ -187,7 +290,45 @@ type (
 		MsgId       int64  `json:"msgId"`       // 服务端消息ID
 		NewMsgId    int64  `json:"newMsgId"`    // 服务端消息ID
 	}
+	// 发送链接卡片
+	SendLinkRequest struct {
+		ToUser   string `json:"toUser"`   // 发送对象
+		Title    string `json:"title"`    // 标题
+		Des      string `json:"des"`      // 描述
+		Url      string `json:"url"`      // 链接地址
+		ThumbUrl string `json:"thumbUrl"` // 缩略图地址
+	}
+	SendLinkResponse struct {
+		ClientMsgId string `json:"clientMsgId"` // 客户端消息ID
+		MsgId       int64  `json:"msgId"`       // 服务端消息ID
+		NewMsgId    int64  `json:"newMsgId"`    // 服务端消息ID
+	}
+	// 发送名片
+	SendCardRequest struct {
+		ToUser   string `json:"toUser"`   // 发送对象
+		CardUser string `json:"cardUser"` // 名片的微信号
+		NickName string `json:"nickName"` // 名片显示的昵称
+	}
+	SendCardResponse struct {
+		MsgId    int64 `json:"msgId"`    // 服务端消息ID
+		NewMsgId int64 `json:"newMsgId"` // 服务端消息ID
+	}
+	// 发送位置
+	SendLocationRequest struct {
+		ToUser    string  `json:"toUser"`    // 发送对象
+		Latitude  float64 `json:"latitude"`  // 纬度
+		Longitude float64 `json:"longitude"` // 经度
+		Scale     int     `json:"scale"`     // 地图缩放级别
+		Label     string  `json:"label"`     // 详细地址
+		PoiName   string  `json:"poiName"`   // 地点名称
+	}
+	SendLocationResponse struct {
+		MsgId    int64 `json:"msgId"`    // 服务端消息ID
+		NewMsgId int64 `json:"newMsgId"` // 服务端消息ID
+	}
 	// 下载图片
+	// 下载接口只返回文件地址,Content是下载失败时的提示而不是文件内容
+	// 需要文件内容时使用ChatBot.OpenMedia
 	DownloadImageRequest struct {
 		XML string `json:"xml"`
 	}
@@ -213,6 +354,17 @@ type (
 		VoiceLength int64  `json:"voiceLength"` // 语音长度
 		VoiceUrl    string `json:"voiceUrl"`    // 语音地址
 	}
+	// 发送引用回复
+	SendQuoteRequest struct {
+		ToUser   string   `json:"toUser"`   // 发送对象
+		NewMsgId int64    `json:"newMsgId"` // 被引用消息的服务端ID
+		AtList   []string `json:"atList"`   // 群内at的人微信号
+		Content  string   `json:"content"`  // 回复内容
+	}
+	SendQuoteResponse struct {
+		MsgId    int64 `json:"msgId"`    // 服务端消息ID
+		NewMsgId int64 `json:"newMsgId"` // 服务端消息ID
+	}
 	// 删除群成员
 	DelGroupRequest struct {
 		Group      string   `json:"chatroom"`   // 群号
@@ -221,4 +373,78 @@ type (
 	DelGroupResponse struct {
 		DelMemberList []string `json:"delMemberList"`
 	}
+	// 直接拉人进群,群人数较少时可用
+	AddGroupRequest struct {
+		Group      string   `json:"chatroom"`   // 群号
+		MemberList []string `json:"memberList"` // 添加人员
+	}
+	AddGroupResponse struct {
+		AddMemberList []string `json:"addMemberList"`
+	}
+	// 发送群邀请,对方同意后进群
+	InviteGroupRequest struct {
+		Group      string   `json:"chatroom"`   // 群号
+		MemberList []string `json:"memberList"` // 邀请人员
+	}
+	InviteGroupResponse struct {
+		InviteMemberList []string `json:"inviteMemberList"`
+	}
+	// 获取群成员列表
+	GetGroupMembersRequest struct {
+		Group string `json:"chatroom"` // 群号
+	}
+	GetGroupMembersResponse struct {
+		Owner      string        `json:"owner"`      // 群主微信号
+		MemberList []GroupMember `json:"memberList"` // 群成员
+	}
+	// 修改群名称
+	SetGroupNameRequest struct {
+		Group string `json:"chatroom"` // 群号
+		Name  string `json:"name"`     // 新群名
+	}
+	SetGroupNameResponse struct{}
+	// 修改群公告
+	SetGroupAnnouncementRequest struct {
+		Group        string `json:"chatroom"`     // 群号
+		Announcement string `json:"announcement"` // 公告内容
+	}
+	SetGroupAnnouncementResponse struct{}
+	// 转让群主
+	TransferGroupOwnerRequest struct {
+		Group    string `json:"chatroom"` // 群号
+		NewOwner string `json:"newOwner"` // 新群主微信号
+	}
+	TransferGroupOwnerResponse struct{}
+	// 设置或者取消群管理员
+	SetGroupAdminRequest struct {
+		Group      string   `json:"chatroom"`   // 群号
+		MemberList []string `json:"memberList"` // 管理员微信号
+	}
+	SetGroupAdminResponse struct{}
 )
+
+// 群成员信息
+type GroupMember struct {
+	UserName    string `json:"userName"`    // 微信号
+	NickName    string `json:"nickName"`    // 微信昵称
+	DisplayName string `json:"displayName"` // 群昵称,未设置时为空
+	HeadImg     string `json:"headImg"`     // 头像
+	Role        int8   `json:"role"`        // 群内身份,1成员,2管理员,3群主
+	InviteBy    string `json:"inviteBy"`    // 邀请人微信号
+}
+
+func (m *GroupMember) IsAdmin() bool {
+	return m.Role == RoleAdmin
+}
+
+func (m *GroupMember) IsGroupOwner() bool {
+	return m.Role == RoleOwner
+}
+
+// Name 群内显示的名称,优先使用群昵称
+func (m *GroupMember) Name() string {
+	if m.DisplayName != "" {
+		return m.DisplayName
+	}
+	return m.NickName
+}
@@ -0,0 +1,30 @@
+package chatbot
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestGroupBotEvent_Unmarshal(t *testing.T) {
+	// 旧版本服务端的推送
+	old := `{"event":100002,"eventText":"新成员加入","group":{"groupUserName":"123@chatroom"},"members":[{"userName":"wxid_a","nickName":"A"}]}`
+	e := &GroupBotEvent{}
+	if err := json.Unmarshal([]byte(old), e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Event != GroupEventNewMember || e.EventText != "新成员加入" || e.ByOperator() || e.Target().UserName != "wxid_a" {
+		t.Errorf("unexpected event: %+v", e)
+	}
+
+	data := `{"event":100004,"group":{"groupUserName":"123@chatroom"},"operator":{"userName":"wxid_b"},"before":"a","after":"b"}`
+	e = &GroupBotEvent{}
+	if err := json.Unmarshal([]byte(data), e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Event != GroupEventRenamed || e.Event.String() != "renamed" || !e.ByOperator() || e.After != "b" || e.Target() != nil {
+		t.Errorf("unexpected event: %+v", e)
+	}
+	if GroupEvent(1).String() != "unknown(1)" {
+		t.Error("unexpected unknown event name")
+	}
+}
@@ -0,0 +1,89 @@
+package chatbot
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrUnsupported 服务端不支持该接口,可以用errors.Is判断
+var ErrUnsupported = errors.New("not supported by server")
+
+// UnsupportedError 服务端没有提供可选接口,参考Feature
+type UnsupportedError struct {
+	Feature  Feature
+	Endpoint string
+	Err      error // 第一次探测时服务端返回的错误,之后为nil
+}
+
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("%s(%s) is not supported by server", e.Feature, e.Endpoint)
+}
+
+func (e *UnsupportedError) Unwrap() error {
+	return e.Err
+}
+
+func (e *UnsupportedError) Is(target error) bool {
+	return target == ErrUnsupported
+}
+
+// StatusError 接口返回了非200的http状态码
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("response status code err:%d", e.StatusCode)
+}
+
+// APIError 接口返回的业务错误,即返回体中code不为0
+type APIError struct {
+	Code int64
+	Msg  string
+}
+
+func (e *APIError) Error() string {
+	return e.Msg
+}
+
+// ErrPermissionDenied 机器人在群内没有执行该操作的权限,例如不是群主或者管理员
+// 可以用errors.Is判断
+var ErrPermissionDenied = errors.New("permission denied")
+
+// PermissionError 群管理操作因为权限不足失败
+type PermissionError struct {
+	Op    string // 操作,例如setChatRoomName
+	Group string // 群号
+	Err   error  // 接口返回的原始错误
+}
+
+func (e *PermissionError) Error() string {
+	return fmt.Sprintf("%s %s: %s: %s", e.Op, e.Group, ErrPermissionDenied, e.Err)
+}
+
+func (e *PermissionError) Unwrap() error {
+	return e.Err
+}
+
+func (e *PermissionError) Is(target error) bool {
+	return target == ErrPermissionDenied
+}
+
+// groupError 把权限不足的接口错误转换为PermissionError
+// http状态码403,或者业务码在WithPermissionCodes设置的列表中时认为是权限不足
+func (bot *ChatBot) groupError(op, group string, err error) error {
+	switch e := err.(type) {
+	case *StatusError:
+		if e.StatusCode == http.StatusForbidden {
+			return &PermissionError{Op: op, Group: group, Err: err}
+		}
+	case *APIError:
+		for _, code := range bot.bot.permissionCodes {
+			if e.Code == code {
+				return &PermissionError{Op: op, Group: group, Err: err}
+			}
+		}
+	}
+	return err
+}
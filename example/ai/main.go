@@ -65,19 +65,20 @@ func (p *AIPlugin) Do(msg *chatbot.PushMessage) error {
 		if err := json.Unmarshal(msg.Data, message); err != nil {
 			return err
 		}
+		// 绑定消息的上下文,开启追踪后回复的发送会关联到这条消息上
+		bot := p.bot.WithContext(msg.Context())
 		// 如果是群内消息
 		if chatbot.IsGroupMessage(message.FromUser) {
 			// 如果是机器人被@了
 			if chatbot.IsBotBeenAt(message) {
-				keyword := chatbot.SplitAtContent(message.GroupContent)
+				_, keyword := message.Mentions(nil)
 				reply, err := OwnThinkAPI(message.GroupMember, keyword)
 				if err != nil {
 					return err
 				}
-				if err := p.bot.SendText(
+				if err := bot.SendTextMessage(
 					message.FromUser,
-					fmt.Sprintf("@%s %s", message.WhoAtBot, reply),
-					[]string{message.GroupMember},
+					chatbot.Text().Mention(message.GroupMember, message.WhoAtBot).Add(reply),
 				); err != nil {
 					return fmt.Errorf("发送群内@回复消息失败:%w", err)
 				}
@@ -87,7 +88,7 @@ func (p *AIPlugin) Do(msg *chatbot.PushMessage) error {
 			if err != nil {
 				return err
 			}
-			if err := p.bot.SendText(
+			if err := bot.SendText(
 				message.FromUser,
 				reply,
 				nil,
@@ -82,14 +82,15 @@ func (p *GroupManagerPlugin) handleMessage(msg *chatbot.UserMessage) error {
 		len(msg.AtList) > 0 {
 		// 判断身份这条消息发送人的身份
 		if !msg.IsAdmin() && !msg.IsGroupOwner() {
-			if err := p.bot.SendText(msg.FromUser, "你不是管理员不能命令我", []string{msg.GroupMember}); err != nil {
+			reply := chatbot.Text().Mention(msg.GroupMember, msg.GroupMemberNickname).Add("你不是管理员不能命令我")
+			if err := p.bot.SendTextMessage(msg.FromUser, reply); err != nil {
 				log.Println("发送消息失败", err)
 				return err
 			}
 			return errors.New("不是管理员身份,不能进行操作")
 		}
 
-		content := chatbot.SplitAtContent(msg.GroupContent)
+		_, content := msg.Mentions(nil)
 		if content == kickKeyword {
 			_, err := p.bot.DelGroupMembers(msg.FromUser, msg.AtList)
 			if err != nil {
@@ -110,12 +111,26 @@ func (p *GroupManagerPlugin) handleGroupEvent(msg *chatbot.GroupBotEvent) error
 		log.Println("机器人被踢出群了!", msg.Group.GroupNickName)
 	case chatbot.GroupEventNewMember:
 		for _, m := range msg.Members {
+			if msg.ByOperator() {
+				return p.bot.SendText(msg.Group.GroupUserName, fmt.Sprintf("欢迎%s邀请的新成员:%s", msg.Operator.NickName, m.NickName), nil)
+			}
 			return p.bot.SendText(msg.Group.GroupUserName, fmt.Sprintf("欢迎新成员:%s", m.NickName), nil)
 		}
 	case chatbot.GroupEventMemberQuit:
 		for _, m := range msg.Members {
+			if msg.ByOperator() {
+				return p.bot.SendText(msg.Group.GroupUserName, fmt.Sprintf("%s被%s移出了群聊", m.NickName, msg.Operator.NickName), nil)
+			}
 			return p.bot.SendText(msg.Group.GroupUserName, fmt.Sprintf("有人离开了:%s", m.NickName), nil)
 		}
+	case chatbot.GroupEventRenamed:
+		return p.bot.SendText(msg.Group.GroupUserName, fmt.Sprintf("群名从「%s」改为了「%s」", msg.Before, msg.After), nil)
+	case chatbot.GroupEventAdminAdded:
+		for _, m := range msg.Members {
+			return p.bot.SendText(msg.Group.GroupUserName, fmt.Sprintf("恭喜%s成为管理员", m.NickName), nil)
+		}
+	case chatbot.GroupEventAnnouncement, chatbot.GroupEventAdminRemoved, chatbot.GroupEventOwnerChanged:
+		log.Println("群信息变动:", msg.Event, msg.EventText)
 	default:
 		log.Println("未知事件")
 	}
@@ -47,15 +47,30 @@ func (ts *MiniProgramDemo) Name() string {
 }
 
 // 发送"小程序"三个字给机器人会返回一个肯德基的小程序
+// 给机器人分享一个小程序,机器人会原样转发回来
 func (ts *MiniProgramDemo) Do(msg *chatbot.PushMessage) error {
 	if msg.MsgType == chatbot.CusMsgTypeUser {
 		// 获取接收人等基本信息
 		message := &chatbot.UserMessage{}
 		_ = json.Unmarshal(msg.Data, message)
 
+		if message.MsgType == chatbot.MsgTypeApplet {
+			content := message.Content
+			if chatbot.IsGroupMessage(message.FromUser) {
+				content = message.GroupContent
+			}
+			app, err := chatbot.ParseAppMessage(content)
+			if err != nil {
+				return err
+			}
+			if app.MiniProgram != nil {
+				return ts.bot.SendMiniProgram(app.MiniProgram.ToSendRequest(message.FromUser))
+			}
+			return nil
+		}
+
 		if message.MsgType == chatbot.MsgTypeText && message.Content == "小程序" {
-			// 小程序相关字段需要从收到的xml中解析
-			// 可以用机器人接收一次小程序,观察下收到的xml结构
+			// 小程序相关字段可以用ParseAppMessage从收到的小程序消息中解析
 			// 其中一些封面图片等非关键字段不一定需要一一对应,可以改成自己想要的
 			return ts.bot.SendMiniProgram(&chatbot.SendMiniProgramRequest{
 				ToUser:            message.FromUser,
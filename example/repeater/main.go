@@ -26,7 +26,9 @@ func init() {
 }
 
 func main() {
-	bot, err := chatbot.New(*host, *token)
+	bot, err := chatbot.New(*host, *token,
+		chatbot.WithDefaultThumb("http://5b0988e595225.cdn.sohucs.com/images/20200213/cfcf842cd2284a5f91de0b1ee60a23b0.jpeg"),
+	)
 	if err != nil {
 		log.Fatalln("连接服务器失败:", err)
 	}
@@ -79,7 +81,7 @@ func (p *RepeatPlugin) Do(msg *chatbot.PushMessage) error {
 // 其中包含了私聊消息和群消息 需要自己判断
 func (p *RepeatPlugin) handleMessage(msg *chatbot.UserMessage) error {
 	if chatbot.IsBotBeenAt(msg) {
-		if err := p.bot.SendText(msg.FromUser, fmt.Sprintf("@%s %s", msg.WhoAtBot, "谁在叫我"), []string{msg.GroupMember}); err != nil {
+		if err := p.bot.SendTextMessage(msg.FromUser, chatbot.Text().Mention(msg.GroupMember, msg.WhoAtBot).Add("谁在叫我")); err != nil {
 			log.Println("发送@回复失败", err)
 		}
 	} else {
@@ -109,13 +111,9 @@ func (p *RepeatPlugin) handleMessage(msg *chatbot.UserMessage) error {
 				}
 			}
 		case chatbot.MsgTypeVideo:
-			if rsp, err := p.bot.DownloadVideo(content); err != nil {
-				return fmt.Errorf("下载视频失败:%w", err)
-			} else {
-				log.Println("视频地址", rsp.VideoUrl)
-				if err := p.bot.SendVideo(msg.FromUser, rsp.VideoUrl, "http://5b0988e595225.cdn.sohucs.com/images/20200213/cfcf842cd2284a5f91de0b1ee60a23b0.jpeg"); err != nil {
-					return fmt.Errorf("发送视频消息失败:%w", err)
-				}
+			// 封面会优先使用原视频的封面,拿不到时使用WithDefaultThumb配置的默认封面
+			if err := p.bot.ForwardVideo(msg.FromUser, msg); err != nil {
+				return fmt.Errorf("转发视频消息失败:%w", err)
 			}
 		case chatbot.MsgTypeEmoji:
 			if md5, l, err := p.bot.ParseEmojiXML(content); err != nil {
@@ -0,0 +1,173 @@
+package chatbot
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 临时文件默认有效期
+const defaultFileTTL = 10 * time.Minute
+
+// ErrNoFileServer 没有通过WithFileServer配置临时文件服务
+var ErrNoFileServer = errors.New("file server is not configured")
+
+// FileServer 临时文件服务
+// 为内存数据和本地文件生成带签名、会过期的地址,供机器人服务端下载后发送
+// 服务端需要能访问到publicURL
+type FileServer struct {
+	publicURL string
+	secret    []byte
+	ttl       time.Duration
+
+	mu    sync.Mutex
+	files map[string]*tempFile
+}
+
+type tempFile struct {
+	name    string
+	data    []byte // 内存数据
+	path    string // 本地文件路径
+	isFile  bool   // 是否为本地文件,data可能为空,不能用来区分
+	expires time.Time
+}
+
+// NewFileServer 新建临时文件服务
+// @publicURL 服务端访问这个文件服务使用的地址,例如 http://1.2.3.4:8099
+// @ttl 文件地址有效期,为0时使用10分钟
+func NewFileServer(publicURL string, ttl time.Duration) *FileServer {
+	if ttl <= 0 {
+		ttl = defaultFileTTL
+	}
+	secret := make([]byte, 32)
+	_, _ = rand.Read(secret)
+	return &FileServer{
+		publicURL: strings.TrimRight(publicURL, "/"),
+		secret:    secret,
+		ttl:       ttl,
+		files:     make(map[string]*tempFile),
+	}
+}
+
+// ListenAndServe 在addr上启动文件服务
+func (fs *FileServer) ListenAndServe(addr string) error {
+	return http.ListenAndServe(addr, fs)
+}
+
+// AddBytes 添加内存数据,返回可以下载的地址
+// @name 文件名,用于确定Content-Type,例如chart.png
+func (fs *FileServer) AddBytes(name string, data []byte) string {
+	return fs.add(&tempFile{name: name, data: data}, fs.ttl)
+}
+
+// AddBytesTTL 和AddBytes一样,但使用单独的有效期
+// 数据保存在内存中,进程重启后地址失效
+func (fs *FileServer) AddBytesTTL(name string, data []byte, ttl time.Duration) string {
+	if ttl <= 0 {
+		ttl = fs.ttl
+	}
+	return fs.add(&tempFile{name: name, data: data}, ttl)
+}
+
+// AddFile 添加本地文件,返回可以下载的地址
+// 文件在地址过期前需要一直存在
+func (fs *FileServer) AddFile(filePath string) (string, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", errors.New(filePath + " is a directory")
+	}
+	return fs.add(&tempFile{name: filepath.Base(filePath), path: filePath, isFile: true}, fs.ttl), nil
+}
+
+func (fs *FileServer) add(f *tempFile, ttl time.Duration) string {
+	idBytes := make([]byte, 16)
+	_, _ = rand.Read(idBytes)
+	id := hex.EncodeToString(idBytes)
+	f.expires = time.Now().Add(ttl)
+
+	fs.mu.Lock()
+	fs.sweep()
+	fs.files[id] = f
+	fs.mu.Unlock()
+
+	expires := strconv.FormatInt(f.expires.Unix(), 10)
+	q := url.Values{}
+	q.Set("expires", expires)
+	q.Set("sign", fs.sign(id, expires))
+	return fs.publicURL + "/files/" + id + "/" + url.PathEscape(f.name) + "?" + q.Encode()
+}
+
+// sweep 清理过期的文件,调用时需要持有锁
+func (fs *FileServer) sweep() {
+	now := time.Now()
+	for id, f := range fs.files {
+		if now.After(f.expires) {
+			delete(fs.files, id)
+		}
+	}
+}
+
+func (fs *FileServer) sign(id, expires string) string {
+	mac := hmac.New(sha256.New, fs.secret)
+	mac.Write([]byte(id + "." + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ServeHTTP 处理 /files/{id}/{name}?expires=&sign= 的下载请求
+func (fs *FileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/files/"), "/", 2)
+	id := parts[0]
+	expires := r.URL.Query().Get("expires")
+	sign := r.URL.Query().Get("sign")
+	if !hmac.Equal([]byte(sign), []byte(fs.sign(id, expires))) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if exp, err := strconv.ParseInt(expires, 10, 64); err != nil || time.Now().Unix() > exp {
+		w.WriteHeader(http.StatusGone)
+		return
+	}
+
+	fs.mu.Lock()
+	f, ok := fs.files[id]
+	fs.mu.Unlock()
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if !f.isFile {
+		http.ServeContent(w, r, f.name, time.Time{}, bytes.NewReader(f.data))
+		return
+	}
+	file, err := os.Open(f.path)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	http.ServeContent(w, r, path.Base(f.name), info.ModTime(), file)
+}
@@ -0,0 +1,44 @@
+package chatbot
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFileServer(t *testing.T) {
+	fs := NewFileServer("http://127.0.0.1:8099/", 0)
+	u := fs.AddBytes("chart.png", []byte("png data"))
+	if !strings.HasPrefix(u, "http://127.0.0.1:8099/files/") {
+		t.Fatalf("unexpected url %s", u)
+	}
+
+	rec := httptest.NewRecorder()
+	fs.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, u, nil))
+	body, _ := ioutil.ReadAll(rec.Body)
+	if rec.Code != http.StatusOK || string(body) != "png data" {
+		t.Errorf("want file content, got %d %s", rec.Code, body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "image/png" {
+		t.Errorf("unexpected content type %s", ct)
+	}
+
+	rec = httptest.NewRecorder()
+	fs.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, strings.Replace(u, "sign=", "sign=0", 1), nil))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("want 403 for bad signature, got %d", rec.Code)
+	}
+}
+
+func TestFileServer_Empty(t *testing.T) {
+	fs := NewFileServer("http://127.0.0.1:8099", 0)
+	for _, data := range [][]byte{nil, {}} {
+		rec := httptest.NewRecorder()
+		fs.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, fs.AddBytes("empty.txt", data), nil))
+		if rec.Code != http.StatusOK || rec.Body.Len() != 0 {
+			t.Errorf("want empty content, got %d %s", rec.Code, rec.Body)
+		}
+	}
+}
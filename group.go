@@ -0,0 +1,118 @@
+package chatbot
+
+import "errors"
+
+// 群管理相关的接口
+// 机器人没有权限时返回*PermissionError,可以用errors.Is(err, ErrPermissionDenied)判断,
+// 默认只识别http状态码403,服务端用业务码表示权限不足时需要通过WithPermissionCodes指定
+// 除DelGroupMembers外的接口没有列在服务端的接口文档中,路径按delChatRoomMember的命名推测,
+// 都是可选接口,服务端不支持时返回ErrUnsupported,参考FeatureGroupAdmin
+
+// AddGroupMembers 直接拉人进群,返回添加成功的成员
+// 群人数较多时服务端会拒绝直接拉人,需要使用InviteGroupMembers
+func (bot *ChatBot) AddGroupMembers(group string, members []string) ([]string, error) {
+	rsp, err := bot.bot.addGroupMembers(bot.context(), &AddGroupRequest{
+		Group:      group,
+		MemberList: members,
+	})
+	if err != nil {
+		return nil, bot.groupError("addChatRoomMember", group, err)
+	}
+	return rsp.AddMemberList, nil
+}
+
+// InviteGroupMembers 发送群邀请,对方同意后进群,返回邀请成功的成员
+func (bot *ChatBot) InviteGroupMembers(group string, members []string) ([]string, error) {
+	rsp, err := bot.bot.inviteGroupMembers(bot.context(), &InviteGroupRequest{
+		Group:      group,
+		MemberList: members,
+	})
+	if err != nil {
+		return nil, bot.groupError("inviteChatRoomMember", group, err)
+	}
+	return rsp.InviteMemberList, nil
+}
+
+// GroupMembers 获取群成员列表,包含成员在群内的身份
+func (bot *ChatBot) GroupMembers(group string) ([]GroupMember, error) {
+	rsp, err := bot.bot.getGroupMembers(bot.context(), &GetGroupMembersRequest{
+		Group: group,
+	})
+	if err != nil {
+		return nil, bot.groupError("getChatRoomMemberList", group, err)
+	}
+	// 部分服务端只返回群主字段,这里补全成员身份
+	for i := range rsp.MemberList {
+		m := &rsp.MemberList[i]
+		if m.Role == 0 {
+			m.Role = RoleMember
+		}
+		if rsp.Owner != "" && m.UserName == rsp.Owner {
+			m.Role = RoleOwner
+		}
+	}
+	return rsp.MemberList, nil
+}
+
+// GroupAdmins 获取群主和管理员
+func (bot *ChatBot) GroupAdmins(group string) ([]GroupMember, error) {
+	members, err := bot.GroupMembers(group)
+	if err != nil {
+		return nil, err
+	}
+	admins := make([]GroupMember, 0)
+	for _, m := range members {
+		if m.IsAdmin() || m.IsGroupOwner() {
+			admins = append(admins, m)
+		}
+	}
+	return admins, nil
+}
+
+// SetGroupName 修改群名称
+func (bot *ChatBot) SetGroupName(group, name string) error {
+	if name == "" {
+		return errors.New("group name is empty")
+	}
+	_, err := bot.bot.setGroupName(bot.context(), &SetGroupNameRequest{
+		Group: group,
+		Name:  name,
+	})
+	return bot.groupError("setChatRoomName", group, err)
+}
+
+// SetGroupAnnouncement 修改群公告,需要群主或者管理员权限
+func (bot *ChatBot) SetGroupAnnouncement(group, announcement string) error {
+	_, err := bot.bot.setGroupAnnouncement(bot.context(), &SetGroupAnnouncementRequest{
+		Group:        group,
+		Announcement: announcement,
+	})
+	return bot.groupError("setChatRoomAnnouncement", group, err)
+}
+
+// TransferGroupOwner 转让群主,需要机器人是群主
+func (bot *ChatBot) TransferGroupOwner(group, newOwner string) error {
+	_, err := bot.bot.transferGroupOwner(bot.context(), &TransferGroupOwnerRequest{
+		Group:    group,
+		NewOwner: newOwner,
+	})
+	return bot.groupError("transferChatRoomOwner", group, err)
+}
+
+// AddGroupAdmins 设置群管理员,需要机器人是群主
+func (bot *ChatBot) AddGroupAdmins(group string, members []string) error {
+	_, err := bot.bot.addGroupAdmins(bot.context(), &SetGroupAdminRequest{
+		Group:      group,
+		MemberList: members,
+	})
+	return bot.groupError("addChatRoomAdmin", group, err)
+}
+
+// DelGroupAdmins 取消群管理员,需要机器人是群主
+func (bot *ChatBot) DelGroupAdmins(group string, members []string) error {
+	_, err := bot.bot.delGroupAdmins(bot.context(), &SetGroupAdminRequest{
+		Group:      group,
+		MemberList: members,
+	})
+	return bot.groupError("delChatRoomAdmin", group, err)
+}
@@ -0,0 +1,108 @@
+package chatbot
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestBot(t *testing.T, h http.HandlerFunc) *ChatBot {
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	host := strings.TrimPrefix(srv.URL, "http://")
+	return &ChatBot{host: host, bot: newBotServer(host, "token", defaultOptions())}
+}
+
+func TestGroupMembers(t *testing.T) {
+	bot := newTestBot(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != urlGroupMembers {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		_, _ = w.Write([]byte(`{"code":0,"data":{"owner":"wxid_a","memberList":[
+			{"userName":"wxid_a","nickName":"A"},
+			{"userName":"wxid_b","nickName":"B","displayName":"bb","role":2},
+			{"userName":"wxid_c","nickName":"C"}]}}`))
+	})
+	members, err := bot.GroupMembers("123@chatroom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !members[0].IsGroupOwner() || !members[1].IsAdmin() || members[2].Role != RoleMember {
+		t.Errorf("unexpected roles: %+v", members)
+	}
+	if members[1].Name() != "bb" {
+		t.Errorf("want display name, got %s", members[1].Name())
+	}
+	admins, err := bot.GroupAdmins("123@chatroom")
+	if err != nil || len(admins) != 2 {
+		t.Errorf("want 2 admins, got %+v, %v", admins, err)
+	}
+}
+
+func TestGroupPermissionDenied(t *testing.T) {
+	bot := newTestBot(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == urlSetGroupName {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte(`{"code":403,"msg":"no permission"}`))
+	})
+	err := bot.SetGroupName("123@chatroom", "new")
+	if !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("want ErrPermissionDenied, got %v", err)
+	}
+	// 没有配置业务码时只是普通的APIError
+	if err := bot.TransferGroupOwner("123@chatroom", "wxid_b"); errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("want plain APIError without permission codes, got %v", err)
+	}
+	bot.bot.permissionCodes = []int64{403}
+	err = bot.TransferGroupOwner("123@chatroom", "wxid_b")
+	var pe *PermissionError
+	if !errors.As(err, &pe) || pe.Op != "transferChatRoomOwner" {
+		t.Errorf("want PermissionError, got %v", err)
+	}
+	var ae *APIError
+	if !errors.As(err, &ae) || ae.Msg != "no permission" {
+		t.Errorf("want wrapped APIError, got %v", err)
+	}
+}
+
+func TestGroupAdmin_EmptyData(t *testing.T) {
+	bodies := []string{`{"code":0,"msg":"ok"}`, `{"code":0,"data":null}`, `{"code":0,"data":{}}`}
+	for _, body := range bodies {
+		bot := newTestBot(t, func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(body))
+		})
+		if err := bot.SetGroupName("123@chatroom", "new"); err != nil {
+			t.Errorf("%s: want success, got %v", body, err)
+		}
+		if err := bot.AddGroupAdmins("123@chatroom", []string{"wxid_a"}); err != nil {
+			t.Errorf("%s: want success, got %v", body, err)
+		}
+	}
+}
+
+func TestGroupAdmin_Unsupported(t *testing.T) {
+	calls := 0
+	bot := newTestBot(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusNotFound)
+	})
+	for i := 0; i < 2; i++ {
+		if err := bot.SetGroupAnnouncement("123@chatroom", "公告"); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("want ErrUnsupported, got %v", err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("want endpoint probed once, got %d calls", calls)
+	}
+	if bot.Supports(FeatureGroupAdmin) || !bot.Supports(FeatureQuote) {
+		t.Error("unexpected capabilities")
+	}
+	// 文档中的接口返回404时不算作不支持
+	if _, err := bot.DelGroupMembers("123@chatroom", []string{"wxid_a"}); errors.Is(err, ErrUnsupported) {
+		t.Errorf("want status error for documented endpoint, got %v", err)
+	}
+}
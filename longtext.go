@@ -0,0 +1,293 @@
+package chatbot
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// defaultMaxRunes 每段默认最大字符数
+	defaultMaxRunes = 1500
+	// defaultLongTextInterval 每段之间默认的发送间隔
+	defaultLongTextInterval = time.Second
+	// numberReserve 编号预留的字符数
+	numberReserve = 10
+	// longTextSummaryRunes LongTextAsFile发送的摘要字符数
+	longTextSummaryRunes = 100
+	// LongTextFileTTL LongTextAsFile上传的全文的有效期
+	LongTextFileTTL = 30 * 24 * time.Hour
+	// trimChars 每段首尾去掉的空白
+	trimChars = " \t\r\n"
+)
+
+// ErrMaxRunesTooSmall 开启编号时MaxRunes太小,放不下编号
+var ErrMaxRunesTooSmall = errors.New("MaxRunes is too small for numbered parts")
+
+// 句子结束的标点
+const sentenceEnds = "。！？；!?;…"
+
+// LongTextOptions SendLongText的配置,零值使用默认配置
+type LongTextOptions struct {
+	// MaxRunes 每段最大字符数,默认1500
+	MaxRunes int
+	// Numbered 是否在每段末尾添加(1/3)形式的编号,编号计入MaxRunes,MaxRunes需要大于20
+	Numbered bool
+	// Interval 每段之间的发送间隔,默认1s
+	Interval time.Duration
+	// FallbackThreshold 总字符数超过该值时交给Fallback发送,0为不启用
+	FallbackThreshold int
+	// Fallback 超长文本的发送方式,默认为LongTextAsFile
+	Fallback func(bot *ChatBot, toUser, content string, atList []string) error
+}
+
+// LongTextAsFile 把全文作为txt文件放到临时文件服务上,发送开头的摘要和文件地址
+// 需要配置WithFileServer,地址有效期为LongTextFileTTL,全文保存在内存中,进程重启后地址失效
+// 全文中被@的人会放在摘要前面,保证都能收到提醒
+func LongTextAsFile(bot *ChatBot, toUser, content string, atList []string) error {
+	if bot.files == nil {
+		return ErrNoFileServer
+	}
+	if !IsGroupMessage(toUser) {
+		atList = nil
+	}
+	if err := checkMentions(content, atList, nil); err != nil {
+		return err
+	}
+	u := bot.files.AddBytesTTL("message.txt", []byte(content), LongTextFileTTL)
+
+	content = strings.Trim(content, trimChars)
+	cut := runeOffset(content, 0, longTextSummaryRunes)
+	var mentions strings.Builder
+	for _, s := range mentionTokens(content, atList, nil) {
+		switch {
+		case s.Start < cut && s.End > cut:
+			// 不能切断@昵称
+			cut = s.End
+		case s.Start >= cut:
+			mention := strings.TrimRight(content[s.Start:s.End], " ")
+			if !strings.HasSuffix(mention, mentionSep) {
+				mention += mentionSep
+			}
+			mentions.WriteString(mention)
+		}
+	}
+	summary := content[:cut]
+	if cut < len(content) {
+		summary += "…"
+	}
+	return bot.SendText(toUser, fmt.Sprintf("%s%s\n\n全文共%d字,点击查看:%s",
+		mentions.String(), summary, utf8.RuneCountInString(content), u), atList)
+}
+
+// SendLongText 分段发送长文本
+// 优先按段落、句子切分,不会切断字符或者@昵称,每段只带上该段内被@的人
+func (bot *ChatBot) SendLongText(toUser, content string, atList []string, opts *LongTextOptions) error {
+	o := LongTextOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.MaxRunes <= 0 {
+		o.MaxRunes = defaultMaxRunes
+	}
+	if o.Interval <= 0 {
+		o.Interval = defaultLongTextInterval
+	}
+	if o.Fallback == nil {
+		o.Fallback = LongTextAsFile
+	}
+	if o.FallbackThreshold > 0 && utf8.RuneCountInString(content) > o.FallbackThreshold {
+		return o.Fallback(bot, toUser, content, atList)
+	}
+	if !IsGroupMessage(toUser) {
+		atList = nil
+	}
+	// 先校验全文,避免发出一部分后才失败
+	if err := checkMentions(content, atList, nil); err != nil {
+		return err
+	}
+
+	parts := splitText(content, o.MaxRunes, atList)
+	if o.Numbered && len(parts) > 1 {
+		if o.MaxRunes <= numberReserve*2 {
+			return ErrMaxRunesTooSmall
+		}
+		// 预留编号的长度,段数变多导致编号变长时重新切分
+		for reserve := numberReserve; ; {
+			parts = splitText(content, o.MaxRunes-reserve, atList)
+			n := utf8.RuneCountInString(numberSuffix(len(parts), len(parts)))
+			if n <= reserve {
+				break
+			}
+			reserve = n
+		}
+	}
+	owners := mentionOwners(content, atList)
+	for i, p := range parts {
+		if i > 0 {
+			select {
+			case <-bot.context().Done():
+				return bot.context().Err()
+			case <-time.After(o.Interval):
+			}
+		}
+		text := p.text
+		if o.Numbered && len(parts) > 1 {
+			text += numberSuffix(i+1, len(parts))
+		}
+		if err := bot.SendText(toUser, text, p.atList(owners)); err != nil {
+			return fmt.Errorf("发送第%d/%d段失败:%w", i+1, len(parts), err)
+		}
+	}
+	return nil
+}
+
+// numberSuffix 每段末尾的编号
+func numberSuffix(i, n int) string {
+	return fmt.Sprintf("\n(%d/%d)", i, n)
+}
+
+// textPart 切分后的一段文本,start和end为在原文中的字节位置
+type textPart struct {
+	text       string
+	start, end int
+}
+
+// atList 这一段内被@的人
+func (p textPart) atList(owners []mentionOwner) []string {
+	var list []string
+	for _, o := range owners {
+		if o.start >= p.start && o.start < p.end && !containsString(list, o.userName) {
+			list = append(list, o.userName)
+		}
+	}
+	return list
+}
+
+// mentionOwner 内容中一个@昵称对应的微信号
+type mentionOwner struct {
+	userName string
+	start    int
+}
+
+// mentionOwners 把内容中的@昵称和atList对应起来
+// 能识别昵称的直接对应,其余的按出现顺序对应剩下的微信号
+func mentionOwners(content string, atList []string) []mentionOwner {
+	if len(atList) == 0 {
+		return nil
+	}
+	segments := mentionTokens(content, atList, nil)
+	rest := make([]string, 0, len(atList))
+	for _, u := range atList {
+		found := false
+		for _, s := range segments {
+			if s.UserName == u {
+				found = true
+				break
+			}
+		}
+		if !found {
+			rest = append(rest, u)
+		}
+	}
+	owners := make([]mentionOwner, 0, len(segments))
+	for _, s := range segments {
+		u := s.UserName
+		if u == "" {
+			if len(rest) == 0 {
+				continue
+			}
+			u, rest = rest[0], rest[1:]
+		}
+		owners = append(owners, mentionOwner{userName: u, start: s.Start})
+	}
+	return owners
+}
+
+// SplitText 把文本切分为每段不超过maxRunes个字符
+// 优先在段落、换行、句子、逗号和空格处切分,不会切断UTF-8字符和@昵称
+// 只有@昵称本身超过maxRunes时才会被切断
+// @atList 内容中被@人的微信号,用于识别@昵称,可以为nil
+func SplitText(content string, maxRunes int, atList []string) []string {
+	parts := splitText(content, maxRunes, atList)
+	list := make([]string, 0, len(parts))
+	for _, p := range parts {
+		list = append(list, p.text)
+	}
+	return list
+}
+
+func splitText(content string, maxRunes int, atList []string) []textPart {
+	if maxRunes <= 0 {
+		maxRunes = defaultMaxRunes
+	}
+	segments, _ := ParseMentions(content, atList, nil)
+
+	var parts []textPart
+	add := func(start, end int) {
+		// 不能用TrimSpace,会去掉@昵称后面的分隔符
+		text := strings.Trim(content[start:end], trimChars)
+		if text != "" {
+			parts = append(parts, textPart{text: text, start: start, end: end})
+		}
+	}
+	offset := 0
+	for utf8.RuneCountInString(content[offset:]) > maxRunes {
+		limit := runeOffset(content, offset, maxRunes)
+		cut := offset + breakPoint(content[offset:limit])
+		// 不能切在@昵称中间
+		for _, s := range segments {
+			if cut > s.Start && cut < s.End {
+				if s.Start > offset {
+					cut = s.Start
+				} else {
+					cut = s.End
+				}
+			}
+		}
+		// @昵称比整段还长时只能按长度切
+		if cut > limit {
+			cut = limit
+		}
+		add(offset, cut)
+		offset = cut
+	}
+	add(offset, len(content))
+	return parts
+}
+
+// breakPoint 在window中找合适的切分位置,返回切分处的字节偏移
+// 段落在window后2/3中找,其他在后半段找,避免切出太短的段落
+func breakPoint(window string) int {
+	if i := strings.LastIndex(window, "\n\n"); i >= len(window)/3 {
+		return i + 2
+	}
+	half := len(window) / 2
+	if i := strings.LastIndex(window, "\n"); i >= half {
+		return i + 1
+	}
+	if i := strings.LastIndexAny(window, sentenceEnds); i >= half {
+		_, size := utf8.DecodeRuneInString(window[i:])
+		return i + size
+	}
+	if i := strings.LastIndex(window, ". "); i >= half {
+		return i + 2
+	}
+	if i := strings.LastIndexAny(window, "，,、 "); i >= half {
+		_, size := utf8.DecodeRuneInString(window[i:])
+		return i + size
+	}
+	return len(window)
+}
+
+// runeOffset 从start开始n个字符后的字节偏移
+func runeOffset(s string, start, n int) int {
+	i := start
+	for ; n > 0 && i < len(s); n-- {
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+	}
+	return i
+}
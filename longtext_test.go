@@ -0,0 +1,154 @@
+package chatbot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestSplitText(t *testing.T) {
+	content := strings.Repeat("这是一个句子。", 10) + "\n\n" + strings.Repeat("第二段内容，", 10)
+	parts := SplitText(content, 50, nil)
+	if len(parts) < 2 {
+		t.Fatalf("want several parts, got %d", len(parts))
+	}
+	for _, p := range parts {
+		if !utf8.ValidString(p) {
+			t.Errorf("invalid utf8 part %q", p)
+		}
+		if n := utf8.RuneCountInString(p); n > 50 {
+			t.Errorf("part too long: %d runes", n)
+		}
+	}
+	if !strings.HasSuffix(parts[0], "。") {
+		t.Errorf("want split at sentence end, got %q", parts[0])
+	}
+	if strings.Join(parts, "") != strings.Replace(content, "\n\n", "", 1) {
+		t.Error("content lost after split")
+	}
+}
+
+func TestSplitText_Mention(t *testing.T) {
+	content := strings.Repeat("啊", 8) + "@小明\u2005" + strings.Repeat("哦", 8)
+	parts := SplitText(content, 10, []string{"wxid_a"})
+	for _, p := range parts {
+		if strings.Contains(p, "@") && !strings.Contains(p, "@小明\u2005") && !strings.HasPrefix(p, "@小明") {
+			t.Errorf("mention was split: %q", parts)
+		}
+	}
+}
+
+func TestSplitText_LongMention(t *testing.T) {
+	content := "@" + strings.Repeat("长", 12) + "\u2005" + strings.Repeat("哦", 5)
+	for _, p := range SplitText(content, 10, []string{"wxid_a"}) {
+		if n := utf8.RuneCountInString(p); n > 10 {
+			t.Errorf("part too long: %d runes %q", n, p)
+		}
+	}
+}
+
+func TestSendLongText_Mention(t *testing.T) {
+	var reqs []*SendTextRequest
+	bot := newTestBot(t, func(w http.ResponseWriter, r *http.Request) {
+		req := &SendTextRequest{}
+		_ = json.NewDecoder(r.Body).Decode(req)
+		reqs = append(reqs, req)
+		_, _ = w.Write([]byte(`{"code":0,"data":{}}`))
+	})
+	content := strings.Repeat("第一段内容。", 5) + "\n\n" + strings.Repeat("第二段内容。", 5) + "\n\n请@小明\u2005处理"
+	err := bot.SendLongText("123@chatroom", content, []string{"wxid_a"}, &LongTextOptions{MaxRunes: 35, Interval: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reqs) < 2 {
+		t.Fatalf("want several parts, got %d", len(reqs))
+	}
+	for i, req := range reqs {
+		has := strings.Contains(req.Content, "@小明\u2005")
+		if has != (len(req.AtList) == 1 && req.AtList[0] == "wxid_a") {
+			t.Errorf("part %d: atList %v does not match content %q", i, req.AtList, req.Content)
+		}
+	}
+	if !strings.Contains(reqs[len(reqs)-1].Content, "@小明\u2005") {
+		t.Errorf("want mention in last part, got %q", reqs[len(reqs)-1].Content)
+	}
+
+	reqs = nil
+	if err := bot.SendLongText("123@chatroom", "a@b.com", []string{"wxid_a"}, nil); err != ErrMentionMismatch || len(reqs) != 0 {
+		t.Errorf("want ErrMentionMismatch before sending, got %v and %d requests", err, len(reqs))
+	}
+}
+
+func TestLongTextAsFile(t *testing.T) {
+	var req SendTextRequest
+	bot := newTestBot(t, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		_, _ = w.Write([]byte(`{"code":0,"data":{}}`))
+	})
+	content := strings.Repeat("很长的内容", 100)
+	if err := bot.SendLongText("wxid_a", content, nil, &LongTextOptions{FallbackThreshold: 100}); err != ErrNoFileServer {
+		t.Errorf("want ErrNoFileServer, got %v", err)
+	}
+	bot.files = NewFileServer("http://127.0.0.1:8099/", 0)
+	if err := bot.SendLongText("wxid_a", content, nil, &LongTextOptions{FallbackThreshold: 100}); err != nil {
+		t.Fatal(err)
+	}
+	summary := strings.SplitN(req.Content, "\n", 2)[0]
+	if !strings.Contains(req.Content, "http://127.0.0.1:8099/files/") || utf8.RuneCountInString(summary) != longTextSummaryRunes+1 {
+		t.Errorf("unexpected fallback message %q", req.Content)
+	}
+}
+
+func TestLongTextAsFile_Mention(t *testing.T) {
+	var req SendTextRequest
+	bot := newTestBot(t, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		_, _ = w.Write([]byte(`{"code":0,"data":{}}`))
+	})
+	bot.files = NewFileServer("http://127.0.0.1:8099/", 0)
+	content := strings.Repeat("很长的内容", 100) + "请@小明\u2005处理"
+	if err := bot.SendLongText("123@chatroom", content, []string{"wxid_a"}, &LongTextOptions{FallbackThreshold: 100}); err != nil {
+		t.Fatal(err)
+	}
+	// 摘要之外的@放到摘要前面,atList不能丢
+	if !strings.HasPrefix(req.Content, "@小明\u2005") || len(req.AtList) != 1 || req.AtList[0] != "wxid_a" {
+		t.Errorf("want mention kept in summary, got %q %v", req.Content, req.AtList)
+	}
+	u, err := url.Parse(req.Content[strings.Index(req.Content, "http://"):])
+	if err != nil {
+		t.Fatal(err)
+	}
+	expires, _ := strconv.ParseInt(u.Query().Get("expires"), 10, 64)
+	if time.Until(time.Unix(expires, 0)) < LongTextFileTTL-time.Minute {
+		t.Errorf("want link valid for LongTextFileTTL, expires at %v", time.Unix(expires, 0))
+	}
+}
+
+func TestSendLongText_Numbered(t *testing.T) {
+	var reqs []*SendTextRequest
+	bot := newTestBot(t, func(w http.ResponseWriter, r *http.Request) {
+		req := &SendTextRequest{}
+		_ = json.NewDecoder(r.Body).Decode(req)
+		reqs = append(reqs, req)
+		_, _ = w.Write([]byte(`{"code":0,"data":{}}`))
+	})
+	content := strings.Repeat("一二三四五六七八九十", 30)
+	opts := &LongTextOptions{MaxRunes: 20, Numbered: true, Interval: time.Millisecond}
+	if err := bot.SendLongText("wxid_a", content, nil, opts); err != ErrMaxRunesTooSmall || len(reqs) != 0 {
+		t.Errorf("want ErrMaxRunesTooSmall, got %v", err)
+	}
+	opts.MaxRunes = 25
+	if err := bot.SendLongText("wxid_a", content, nil, opts); err != nil {
+		t.Fatal(err)
+	}
+	for _, req := range reqs {
+		if n := utf8.RuneCountInString(req.Content); n > 25 {
+			t.Errorf("part with number too long: %d runes %q", n, req.Content)
+		}
+	}
+}
@@ -0,0 +1,401 @@
+package chatbot
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// 下载媒体文件默认的最大大小
+	defaultMaxMediaSize = 100 << 20
+	// 下载媒体文件默认的超时时间
+	defaultDownloadTimeout = 5 * time.Minute
+)
+
+// defaultDownloadClient 没有通过WithHTTPClient配置时使用的客户端
+var defaultDownloadClient = &http.Client{Timeout: defaultDownloadTimeout}
+
+// ErrMediaTooLarge 媒体文件超过了大小限制
+var ErrMediaTooLarge = errors.New("media is too large")
+
+// MediaInfo 媒体文件信息
+type MediaInfo struct {
+	MsgType     int    // 消息类型
+	Key         string // 缓存key,图片、视频、表情为md5
+	Url         string // 下载地址
+	Size        int64  // 文件大小,未知时为-1
+	ContentType string // 下载时返回的Content-Type,命中缓存时为空
+	Cached      bool   // 是否命中缓存
+}
+
+// OpenMedia 获取图片、视频、语音、表情消息的内容
+// 配置了WithMediaCache时会缓存到本地,命中缓存时不会请求服务端
+// 返回的io.ReadCloser需要调用方关闭
+func (bot *ChatBot) OpenMedia(msg *UserMessage) (io.ReadCloser, *MediaInfo, error) {
+	content := msg.Content
+	if IsGroupMessage(msg.FromUser) {
+		content = msg.GroupContent
+	}
+	info, err := mediaInfo(msg, content)
+	if err != nil {
+		return nil, nil, err
+	}
+	if bot.media != nil && info.Key != "" {
+		if f, size, ok := bot.media.Get(info.Key); ok {
+			info.Size = size
+			info.Cached = true
+			return f, info, nil
+		}
+	}
+	if info.Url, err = bot.mediaUrl(msg, content); err != nil {
+		return nil, nil, err
+	}
+
+	body, err := bot.download(info)
+	if err != nil {
+		return nil, nil, err
+	}
+	if bot.media == nil || info.Key == "" {
+		return body, info, nil
+	}
+
+	r := &errReader{r: body}
+	f, size, err := bot.media.put(info.Key, r)
+	_ = body.Close()
+	if err != nil {
+		if r.err != nil {
+			return nil, nil, r.err
+		}
+		// 缓存写入失败不影响获取内容,不经过缓存重新下载
+		log.Printf("写入媒体缓存失败:%s\n", err)
+		body, err := bot.download(info)
+		if err != nil {
+			return nil, nil, err
+		}
+		return body, info, nil
+	}
+	info.Size = size
+	return f, info, nil
+}
+
+// download 下载媒体文件,同时填充info中的大小和Content-Type
+func (bot *ChatBot) download(info *MediaInfo) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(bot.context(), http.MethodGet, info.Url, nil)
+	if err != nil {
+		return nil, err
+	}
+	rsp, err := bot.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if rsp.StatusCode != http.StatusOK {
+		_ = rsp.Body.Close()
+		return nil, &StatusError{StatusCode: rsp.StatusCode}
+	}
+	info.Size = rsp.ContentLength
+	info.ContentType = rsp.Header.Get("Content-Type")
+	maxSize := bot.maxMediaSize()
+	if info.Size > maxSize {
+		_ = rsp.Body.Close()
+		return nil, ErrMediaTooLarge
+	}
+	return &limitedReadCloser{r: rsp.Body, n: maxSize}, nil
+}
+
+// errReader 记录读取时的错误,用于区分下载失败和缓存写入失败
+type errReader struct {
+	r   io.Reader
+	err error
+}
+
+func (e *errReader) Read(p []byte) (int, error) {
+	n, err := e.r.Read(p)
+	if err != nil && err != io.EOF {
+		e.err = err
+	}
+	return n, err
+}
+
+// mediaInfo 从消息xml中获取缓存key,不会请求服务端
+func mediaInfo(msg *UserMessage, content string) (*MediaInfo, error) {
+	info := &MediaInfo{MsgType: msg.MsgType, Size: -1}
+	switch msg.MsgType {
+	case MsgTypeImg:
+		if img, err := ParseImageXML(content); err == nil {
+			info.Key = img.Md5
+		}
+	case MsgTypeVideo:
+		if video, err := ParseVideoXML(content); err == nil {
+			info.Key = video.Md5
+		}
+	case MsgTypeVoice:
+		// 语音没有md5,使用消息id区分
+		info.Key = "voice-" + strconv.FormatInt(msg.NewMsgID, 10)
+	case MsgTypeEmoji:
+		if emoji, err := ParseEmojiXML(content); err == nil {
+			info.Key = emoji.Md5
+		}
+	default:
+		return nil, fmt.Errorf("msgType %d is not media", msg.MsgType)
+	}
+	return info, nil
+}
+
+// mediaUrl 通过服务端获取媒体文件的下载地址
+func (bot *ChatBot) mediaUrl(msg *UserMessage, content string) (string, error) {
+	var u string
+	switch msg.MsgType {
+	case MsgTypeImg:
+		rsp, err := bot.DownloadPic(content)
+		if err != nil {
+			return "", err
+		}
+		u = rsp.ImgUrl
+	case MsgTypeVideo:
+		rsp, err := bot.DownloadVideo(content)
+		if err != nil {
+			return "", err
+		}
+		u = rsp.VideoUrl
+	case MsgTypeVoice:
+		rsp, err := bot.DownloadVoice(msg.NewMsgID, content)
+		if err != nil {
+			return "", err
+		}
+		u = rsp.VoiceUrl
+	case MsgTypeEmoji:
+		emoji, err := bot.DownloadEmoji(content)
+		if err != nil {
+			return "", err
+		}
+		u = emoji
+	}
+	if u == "" {
+		return "", errors.New("media url is empty")
+	}
+	return u, nil
+}
+
+// httpClient 下载媒体文件使用的http客户端
+func (bot *ChatBot) httpClient() *http.Client {
+	if bot.client != nil {
+		return bot.client
+	}
+	return defaultDownloadClient
+}
+
+func (bot *ChatBot) maxMediaSize() int64 {
+	if bot.media != nil && bot.media.MaxFileSize > 0 {
+		return bot.media.MaxFileSize
+	}
+	return defaultMaxMediaSize
+}
+
+// limitedReadCloser 超过n字节后返回ErrMediaTooLarge
+type limitedReadCloser struct {
+	r io.ReadCloser
+	n int64
+}
+
+func (l *limitedReadCloser) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		// 正好读完时多读一个字节判断是否还有数据
+		var b [1]byte
+		if n, _ := l.r.Read(b[:]); n > 0 {
+			return 0, ErrMediaTooLarge
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
+
+func (l *limitedReadCloser) Close() error {
+	return l.r.Close()
+}
+
+// MediaCache 本地媒体文件缓存,按key存放,超过有效期或者总大小时淘汰
+type MediaCache struct {
+	dir string
+
+	// TTL 缓存有效期,为0时不过期
+	TTL time.Duration
+	// MaxBytes 缓存总大小,超过后淘汰最久未使用的文件,为0时不限制
+	MaxBytes int64
+	// MaxFileSize 单个文件的最大大小,为0时使用100MB
+	MaxFileSize int64
+
+	mu sync.Mutex
+	// 文件最后访问时间,用于淘汰,没有记录的使用写入时间
+	access map[string]time.Time
+}
+
+// NewMediaCache 新建媒体文件缓存
+// @dir 缓存目录,不存在时会创建
+func NewMediaCache(dir string, ttl time.Duration, maxBytes int64) (*MediaCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &MediaCache{dir: dir, TTL: ttl, MaxBytes: maxBytes, access: make(map[string]time.Time)}, nil
+}
+
+// path key可能来自外部数据,统一hash后作为文件名
+func (c *MediaCache) path(key string) string {
+	sum := md5.Sum([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+}
+
+// Get 获取缓存文件,过期时返回false
+func (c *MediaCache) Get(key string) (io.ReadCloser, int64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p := c.path(key)
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, 0, false
+	}
+	if c.TTL > 0 && time.Since(info.ModTime()) > c.TTL {
+		_ = os.Remove(p)
+		delete(c.access, p)
+		return nil, 0, false
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, 0, false
+	}
+	c.access[p] = time.Now()
+	return f, info.Size(), true
+}
+
+// Put 写入缓存,写入失败时不会留下不完整的文件
+// 超过MaxBytes的文件不会缓存
+func (c *MediaCache) Put(key string, r io.Reader) error {
+	f, _, err := c.put(key, r)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// put 写入缓存并返回写入文件的句柄,这次写入的文件不会被淘汰
+// 超过MaxBytes时不缓存,返回的句柄关闭时删除临时文件
+func (c *MediaCache) put(key string, r io.Reader) (io.ReadCloser, int64, error) {
+	tmp, err := ioutil.TempFile(c.dir, "tmp-")
+	if err != nil {
+		return nil, 0, err
+	}
+	size, err := io.Copy(tmp, r)
+	if err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return nil, 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return nil, 0, err
+	}
+	if c.MaxBytes > 0 && size > c.MaxBytes {
+		f, err := os.Open(tmp.Name())
+		if err != nil {
+			_ = os.Remove(tmp.Name())
+			return nil, 0, err
+		}
+		return &removeOnClose{File: f}, size, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p := c.path(key)
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		_ = os.Remove(tmp.Name())
+		return nil, 0, err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, 0, err
+	}
+	c.access[p] = time.Now()
+	c.evict(p)
+	return f, size, nil
+}
+
+// removeOnClose 关闭时删除的临时文件
+type removeOnClose struct {
+	*os.File
+}
+
+func (r *removeOnClose) Close() error {
+	err := r.File.Close()
+	_ = os.Remove(r.Name())
+	return err
+}
+
+// evict 清理过期文件,并在超过总大小时按访问时间淘汰,调用时需要持有锁
+// @keep 刚写入的文件,不会被淘汰
+func (c *MediaCache) evict(keep string) {
+	files, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+	type entry struct {
+		path  string
+		size  int64
+		atime time.Time
+	}
+	var (
+		entries []entry
+		total   int64
+	)
+	for _, f := range files {
+		if f.IsDir() || strings.HasPrefix(f.Name(), "tmp-") {
+			continue
+		}
+		p := filepath.Join(c.dir, f.Name())
+		if c.TTL > 0 && time.Since(f.ModTime()) > c.TTL {
+			_ = os.Remove(p)
+			delete(c.access, p)
+			continue
+		}
+		atime, ok := c.access[p]
+		if !ok {
+			atime = f.ModTime()
+		}
+		entries = append(entries, entry{path: p, size: f.Size(), atime: atime})
+		total += f.Size()
+	}
+	if c.MaxBytes <= 0 || total <= c.MaxBytes {
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].atime.Before(entries[j].atime)
+	})
+	for _, e := range entries {
+		if total <= c.MaxBytes {
+			break
+		}
+		if e.path == keep {
+			continue
+		}
+		if os.Remove(e.path) == nil {
+			delete(c.access, e.path)
+			total -= e.size
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package chatbot
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMediaCache(t *testing.T) {
+	c, err := NewMediaCache(t.TempDir(), time.Hour, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Put("md5-a", strings.NewReader("aaaaaa")); err != nil {
+		t.Fatal(err)
+	}
+	f, size, ok := c.Get("md5-a")
+	if !ok || size != 6 {
+		t.Fatalf("want cached file of 6 bytes, got %v %d", ok, size)
+	}
+	data, _ := ioutil.ReadAll(f)
+	_ = f.Close()
+	if string(data) != "aaaaaa" {
+		t.Errorf("unexpected cached data %q", data)
+	}
+
+	// 超过总大小后淘汰最久未使用的文件
+	time.Sleep(10 * time.Millisecond)
+	if err := c.Put("md5-b", strings.NewReader("bbbbbb")); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := c.Get("md5-a"); ok {
+		t.Error("want md5-a evicted")
+	}
+	if _, _, ok := c.Get("md5-b"); !ok {
+		t.Error("want md5-b cached")
+	}
+}
+
+func TestLimitedReadCloser(t *testing.T) {
+	r := &limitedReadCloser{r: ioutil.NopCloser(strings.NewReader("123456")), n: 4}
+	if _, err := ioutil.ReadAll(r); err != ErrMediaTooLarge {
+		t.Errorf("want ErrMediaTooLarge, got %v", err)
+	}
+	r = &limitedReadCloser{r: ioutil.NopCloser(strings.NewReader("1234")), n: 4}
+	if data, err := ioutil.ReadAll(r); err != nil || string(data) != "1234" {
+		t.Errorf("unexpected read %q %v", data, err)
+	}
+}
+
+func TestOpenMedia_Cache(t *testing.T) {
+	var apiCalls, downloads int
+	var host string
+	bot := newTestBot(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/img.jpg" {
+			downloads++
+			_, _ = w.Write([]byte("jpeg data"))
+			return
+		}
+		apiCalls++
+		_, _ = w.Write([]byte(`{"code":0,"data":{"imgUrl":"http://` + host + `/img.jpg"}}`))
+	})
+	host = bot.host
+	cache, err := NewMediaCache(t.TempDir(), time.Hour, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bot.media = cache
+	bot.client = &http.Client{Timeout: time.Second}
+
+	msg := &UserMessage{MsgType: MsgTypeImg, Content: `<msg><img md5="a9d1c5d6" length="9" /></msg>`}
+	for i := 0; i < 2; i++ {
+		f, info, err := bot.OpenMedia(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := ioutil.ReadAll(f)
+		_ = f.Close()
+		if string(data) != "jpeg data" || info.Cached != (i == 1) {
+			t.Errorf("open %d: unexpected media %q %+v", i, data, info)
+		}
+	}
+	if apiCalls != 1 || downloads != 1 {
+		t.Errorf("want cache hit without requests, got %d api calls and %d downloads", apiCalls, downloads)
+	}
+}
+
+func TestOpenMedia_FileLargerThanCache(t *testing.T) {
+	var host string
+	bot := newTestBot(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/img.jpg" {
+			_, _ = w.Write([]byte("jpeg data"))
+			return
+		}
+		_, _ = w.Write([]byte(`{"code":0,"data":{"imgUrl":"http://` + host + `/img.jpg"}}`))
+	})
+	host = bot.host
+	dir := t.TempDir()
+	cache, err := NewMediaCache(dir, time.Hour, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bot.media = cache
+
+	// 文件超过MaxBytes时不缓存,但仍然返回下载的内容
+	msg := &UserMessage{MsgType: MsgTypeImg, Content: `<msg><img md5="a9d1c5d6" length="9" /></msg>`}
+	f, info, err := bot.OpenMedia(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadAll(f)
+	_ = f.Close()
+	if string(data) != "jpeg data" || info.Size != 9 {
+		t.Errorf("unexpected media %q %+v", data, info)
+	}
+	if _, _, ok := cache.Get("a9d1c5d6"); ok {
+		t.Error("want file larger than MaxBytes not cached")
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+		t.Errorf("want temp file removed, got %d files", len(files))
+	}
+}
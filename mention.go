@@ -0,0 +1,306 @@
+package chatbot
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// mentionSep 微信中@昵称后面跟的分隔符,不是普通空格
+	mentionSep = "\u2005"
+	// atAllNickname @所有人时显示的昵称
+	atAllNickname = "所有人"
+)
+
+// ErrMentionMismatch atList和消息内容中的@不一致
+var ErrMentionMismatch = errors.New("atList does not match mentions in content")
+
+// TextBuilder 文本消息构造器,同时生成Content和AtList
+//
+//	chatbot.Text().Mention(wxid, nickname).Add("你好")
+type TextBuilder struct {
+	content   strings.Builder
+	atList    []string
+	nicknames map[string]string
+}
+
+// Text 新建文本消息构造器
+func Text() *TextBuilder {
+	return &TextBuilder{}
+}
+
+// Mention @群成员
+// @wxid 被@人的微信号
+// @nickname 被@人的群昵称,会显示在消息中
+func (t *TextBuilder) Mention(wxid, nickname string) *TextBuilder {
+	t.content.WriteString("@" + nickname + mentionSep)
+	if t.nicknames == nil {
+		t.nicknames = make(map[string]string)
+	}
+	t.nicknames[wxid] = nickname
+	for _, u := range t.atList {
+		if u == wxid {
+			return t
+		}
+	}
+	t.atList = append(t.atList, wxid)
+	return t
+}
+
+// MentionAll @所有人,需要机器人为群主或者管理员
+func (t *TextBuilder) MentionAll() *TextBuilder {
+	return t.Mention(AtAllUser, atAllNickname)
+}
+
+// Add 追加文本内容
+func (t *TextBuilder) Add(text string) *TextBuilder {
+	t.content.WriteString(text)
+	return t
+}
+
+// Content 消息内容
+func (t *TextBuilder) Content() string {
+	return t.content.String()
+}
+
+// AtList 被@人的微信号列表
+func (t *TextBuilder) AtList() []string {
+	return t.atList
+}
+
+// checkMentions 校验atList中的每个人在内容中都有对应的@昵称
+// @昵称后面跟着分隔符、普通空格或者在内容末尾时才算,邮箱地址等普通的@不算
+// @nicknames 微信号到昵称的映射,提供时按昵称逐个匹配,否则只校验@昵称的数量,可以为nil
+func checkMentions(content string, atList []string, nicknames map[string]string) error {
+	if len(atList) == 0 {
+		return nil
+	}
+	matched := make(map[string]bool, len(atList))
+	unknown := 0
+	for _, s := range mentionTokens(content, atList, nicknames) {
+		if s.UserName != "" {
+			matched[s.UserName] = true
+		} else {
+			unknown++
+		}
+	}
+	// 没有昵称的微信号只能和未识别的@昵称按数量对应
+	for _, u := range atList {
+		if matched[u] {
+			continue
+		}
+		if nicknames[u] != "" || unknown == 0 {
+			return ErrMentionMismatch
+		}
+		unknown--
+	}
+	return nil
+}
+
+// mentionTokens 内容中真正的@昵称,按位置排序
+// 在ParseMentions的基础上去掉后面没有分隔符的片段,并补充"@昵称 "的写法
+func mentionTokens(content string, atList []string, nicknames map[string]string) []MentionSegment {
+	segments, _ := ParseMentions(content, atList, nicknames)
+	tokens := make([]MentionSegment, 0, len(segments))
+	for _, s := range segments {
+		if isMentionEnd(content, s.End) {
+			tokens = append(tokens, s)
+		}
+	}
+	tokens = append(tokens, spaceMentions(content, segments)...)
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].Start < tokens[j].Start
+	})
+	return tokens
+}
+
+// isMentionEnd 片段是否以分隔符或者普通空格结束,或者在内容末尾
+func isMentionEnd(content string, end int) bool {
+	return end == len(content) || strings.HasSuffix(content[:end], mentionSep) || strings.HasSuffix(content[:end], " ")
+}
+
+// spaceMentions ParseMentions没有识别的"@昵称 "
+// 兼容fmt.Sprintf("@%s %s", nickname, reply)的写法,@需要在开头或者空白之后
+func spaceMentions(content string, segments []MentionSegment) []MentionSegment {
+	var list []MentionSegment
+	for from := 0; from < len(content); {
+		i := strings.Index(content[from:], "@")
+		if i < 0 {
+			break
+		}
+		start := from + i
+		from = start + 1
+		if start > 0 {
+			if r, _ := utf8.DecodeLastRuneInString(content[:start]); !unicode.IsSpace(r) {
+				continue
+			}
+		}
+		end := len(content)
+		if j := strings.IndexAny(content[start+1:], " \n@"); j >= 0 {
+			end = start + 1 + j
+			if content[end] != ' ' {
+				continue
+			}
+		}
+		if end == start+1 || overlaps(segments, start, end) {
+			continue
+		}
+		list = append(list, MentionSegment{NickName: content[start+1 : end], Start: start, End: skipSeparator(content, end)})
+		from = end
+	}
+	return list
+}
+
+// MentionSegment 消息内容中的一个@
+type MentionSegment struct {
+	UserName string // 被@人的微信号,无法确定时为空
+	NickName string // @后面的昵称
+	Start    int    // 在内容中的起始位置,单位字节
+	End      int    // 在内容中的结束位置,包含后面的分隔符
+}
+
+// 不认识昵称时,@后面最多匹配的字符数
+const maxMentionRunes = 32
+
+// ParseMentions 解析消息内容中的@,返回所有@片段和去掉@后的文本
+// @atList 被@人的微信号,一般为UserMessage.AtList
+// @nicknames 微信号到群昵称的映射,提供后可以准确识别包含空格的昵称,可以为nil
+func ParseMentions(content string, atList []string, nicknames map[string]string) ([]MentionSegment, string) {
+	var segments []MentionSegment
+
+	// 已知昵称的精确匹配,长昵称优先避免前缀冲突
+	known := make([]MentionSegment, 0, len(atList))
+	for _, u := range atList {
+		nick := nicknames[u]
+		if nick == "" && u == AtAllUser {
+			nick = atAllNickname
+		}
+		if nick != "" {
+			known = append(known, MentionSegment{UserName: u, NickName: nick})
+		}
+	}
+	sort.SliceStable(known, func(i, j int) bool {
+		return len(known[i].NickName) > len(known[j].NickName)
+	})
+	for _, k := range known {
+		token := "@" + k.NickName
+		for from := 0; from < len(content); {
+			i := strings.Index(content[from:], token)
+			if i < 0 {
+				break
+			}
+			start := from + i
+			end := start + len(token)
+			from = end
+			if overlaps(segments, start, end) {
+				continue
+			}
+			segments = append(segments, MentionSegment{
+				UserName: k.UserName,
+				NickName: k.NickName,
+				Start:    start,
+				End:      skipSeparator(content, end),
+			})
+		}
+	}
+
+	// 微信客户端发出的@后面都跟着分隔符,以此识别未知昵称
+	for from := 0; from < len(content); {
+		i := strings.Index(content[from:], "@")
+		if i < 0 {
+			break
+		}
+		start := from + i
+		from = start + 1
+		if overlaps(segments, start, start+1) {
+			continue
+		}
+		j := strings.Index(content[start+1:], mentionSep)
+		if j <= 0 {
+			continue
+		}
+		nick := content[start+1 : start+1+j]
+		if strings.ContainsAny(nick, "@\n") || utf8.RuneCountInString(nick) > maxMentionRunes {
+			continue
+		}
+		end := start + 1 + j + len(mentionSep)
+		if overlaps(segments, start, end) {
+			continue
+		}
+		seg := MentionSegment{NickName: nick, Start: start, End: end}
+		if nick == atAllNickname {
+			seg.UserName = AtAllUser
+		}
+		segments = append(segments, seg)
+		from = end
+	}
+
+	// 兼容@昵称后面是普通空格的情况,只处理开头的一个@
+	if len(segments) == 0 && len(atList) > 0 && strings.HasPrefix(content, "@") {
+		if i := strings.Index(content, " "); i > 1 {
+			segments = append(segments, MentionSegment{NickName: content[1:i], Start: 0, End: i + 1})
+		}
+	}
+
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i].Start < segments[j].Start
+	})
+	return segments, stripSegments(content, segments)
+}
+
+// Mentions 解析群消息内容中的@,参考ParseMentions
+func (m *UserMessage) Mentions(nicknames map[string]string) ([]MentionSegment, string) {
+	content := m.Content
+	if IsGroupMessage(m.FromUser) {
+		content = m.GroupContent
+	}
+	atList := m.AtList
+	if len(atList) == 0 {
+		atList = m.Source().AtUserList
+	}
+	return ParseMentions(content, atList, nicknames)
+}
+
+// skipSeparator 跳过@昵称后面的一个分隔符
+func skipSeparator(content string, i int) int {
+	if strings.HasPrefix(content[i:], mentionSep) {
+		return i + len(mentionSep)
+	}
+	if strings.HasPrefix(content[i:], " ") {
+		return i + 1
+	}
+	return i
+}
+
+func overlaps(segments []MentionSegment, start, end int) bool {
+	for _, s := range segments {
+		if start < s.End && s.Start < end {
+			return true
+		}
+	}
+	return false
+}
+
+// stripSegments 去掉内容中的@片段,segments需要按位置排序
+func stripSegments(content string, segments []MentionSegment) string {
+	var b strings.Builder
+	last := 0
+	for _, s := range segments {
+		part := content[last:s.Start]
+		// 句子中间的@去掉后避免留下连续的空格
+		if strings.HasSuffix(b.String(), " ") {
+			part = strings.TrimLeft(part, " ")
+		}
+		b.WriteString(part)
+		last = s.End
+	}
+	rest := content[last:]
+	if strings.HasSuffix(b.String(), " ") {
+		rest = strings.TrimLeft(rest, " ")
+	}
+	b.WriteString(rest)
+	return strings.TrimSpace(b.String())
+}
@@ -0,0 +1,100 @@
+package chatbot
+
+import "testing"
+
+func TestTextBuilder(t *testing.T) {
+	text := Text().Mention("wxid_a", "小明").Mention("wxid_b", "小红").Add("开会了")
+	if text.Content() != "@小明\u2005@小红\u2005开会了" {
+		t.Errorf("unexpected content %q", text.Content())
+	}
+	if at := text.AtList(); len(at) != 2 || at[0] != "wxid_a" || at[1] != "wxid_b" {
+		t.Errorf("unexpected atList %v", at)
+	}
+	if err := checkMentions(text.Content(), text.AtList(), text.nicknames); err != nil {
+		t.Error(err)
+	}
+
+	all := Text().MentionAll().Add("通知")
+	if all.Content() != "@所有人\u2005通知" || all.AtList()[0] != AtAllUser {
+		t.Errorf("unexpected @all message %q %v", all.Content(), all.AtList())
+	}
+}
+
+func TestCheckMentions(t *testing.T) {
+	cases := []struct {
+		content   string
+		atList    []string
+		nicknames map[string]string
+		ok        bool
+	}{
+		{"没有at", []string{"wxid_a"}, nil, false},
+		{"没有at", nil, nil, true},
+		// 邮箱地址不是@昵称
+		{"mail me at a@b.com", []string{"wxid_a"}, nil, false},
+		// 兼容@昵称后面是普通空格
+		{"@小明 开会", []string{"wxid_a"}, nil, true},
+		{"@小明 @小红 开会", []string{"wxid_a", "wxid_b"}, nil, true},
+		{"开会 @小明", []string{"wxid_a"}, nil, true},
+		{"@小明\u2005开会", []string{"wxid_a"}, nil, true},
+		{"@小明\u2005开会", []string{"wxid_a", "wxid_b"}, nil, false},
+		{"@小明\u2005@小红\u2005开会", []string{"wxid_a", "wxid_b"}, nil, true},
+		// 有昵称时逐个匹配
+		{"@小明\u2005开会", []string{"wxid_a"}, map[string]string{"wxid_a": "小红"}, false},
+		{"@小明\u2005开会", []string{"wxid_a"}, map[string]string{"wxid_a": "小明"}, true},
+		{"@所有人\u2005通知", []string{AtAllUser}, nil, true},
+	}
+	for _, c := range cases {
+		err := checkMentions(c.content, c.atList, c.nicknames)
+		if c.ok && err != nil || !c.ok && err != ErrMentionMismatch {
+			t.Errorf("%q %v: unexpected result %v", c.content, c.atList, err)
+		}
+	}
+}
+
+func TestParseMentions(t *testing.T) {
+	cases := []struct {
+		content   string
+		atList    []string
+		nicknames map[string]string
+		users     []string
+		text      string
+	}{
+		// 昵称中有空格
+		{"@Tom Lee\u2005讲个笑话", []string{"wxid_a"}, nil, []string{""}, "讲个笑话"},
+		// 多个@并且在句子中间
+		{"请 @小明\u2005和 @小红\u2005看一下", []string{"wxid_a", "wxid_b"},
+			map[string]string{"wxid_a": "小明", "wxid_b": "小红"}, []string{"wxid_a", "wxid_b"}, "请 和 看一下"},
+		// 已知昵称,后面是普通空格
+		{"@Tom Lee 你好", []string{"wxid_a"}, map[string]string{"wxid_a": "Tom Lee"}, []string{"wxid_a"}, "你好"},
+		// @所有人
+		{"@所有人\u2005开会", []string{AtAllUser}, nil, []string{AtAllUser}, "开会"},
+		// 兼容普通空格
+		{"@test 讲个笑话", []string{"wxid_a"}, nil, []string{""}, "讲个笑话"},
+		// 没有@
+		{"邮箱是a@b.com", nil, nil, nil, "邮箱是a@b.com"},
+	}
+	for i, c := range cases {
+		segments, text := ParseMentions(c.content, c.atList, c.nicknames)
+		if text != c.text {
+			t.Errorf("case %d: want text %q, got %q", i, c.text, text)
+		}
+		if len(segments) != len(c.users) {
+			t.Errorf("case %d: want %d segments, got %+v", i, len(c.users), segments)
+			continue
+		}
+		for j, s := range segments {
+			if s.UserName != c.users[j] {
+				t.Errorf("case %d: want user %q, got %q", i, c.users[j], s.UserName)
+			}
+		}
+	}
+}
+
+func TestIsBotBeenAt(t *testing.T) {
+	if !IsBotBeenAt(&UserMessage{ClientUserName: "wxid_bot", AtList: []string{AtAllUser}}) {
+		t.Error("want @all recognized")
+	}
+	if IsBotBeenAt(&UserMessage{ClientUserName: "wxid_bot", AtList: []string{"wxid_a"}}) {
+		t.Error("bot is not mentioned")
+	}
+}
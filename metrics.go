@@ -0,0 +1,46 @@
+package chatbot
+
+import "time"
+
+// Metrics 运行指标收集接口
+// 可以自行实现后通过WithMetrics接入,也可以使用metrics子包中的Prometheus实现
+type Metrics interface {
+	// WsConnected WebSocket连接成功
+	WsConnected()
+	// WsDisconnected WebSocket连接断开
+	WsDisconnected()
+	// WsReconnectAttempt 发起一次重连,err为本次重连的结果
+	WsReconnectAttempt(err error)
+	// MessageReceived 收到一条推送消息
+	// msgType为UserMessage中的MsgType,非用户消息时为0
+	MessageReceived(pushType PushMsgType, msgType int)
+	// PluginHandled 插件处理完一条消息
+	PluginHandled(name string, duration time.Duration, err error)
+	// APIRequested 调用了一次机器人http接口
+	// code为接口返回的业务码,网络错误时为-1,http状态码错误时为对应状态码
+	APIRequested(path string, duration time.Duration, code int64, err error)
+}
+
+// nopMetrics 默认不收集任何指标
+type nopMetrics struct{}
+
+func (nopMetrics) WsConnected()                                     {}
+func (nopMetrics) WsDisconnected()                                  {}
+func (nopMetrics) WsReconnectAttempt(error)                         {}
+func (nopMetrics) MessageReceived(PushMsgType, int)                 {}
+func (nopMetrics) PluginHandled(string, time.Duration, error)       {}
+func (nopMetrics) APIRequested(string, time.Duration, int64, error) {}
+
+// errorCode 从baseRequest返回的错误中提取指标使用的错误码
+func errorCode(err error) int64 {
+	switch e := err.(type) {
+	case nil:
+		return 0
+	case *APIError:
+		return e.Code
+	case *StatusError:
+		return int64(e.StatusCode)
+	default:
+		return -1
+	}
+}
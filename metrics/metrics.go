@@ -0,0 +1,283 @@
+// Package metrics 提供chatbot.Metrics的Prometheus文本格式实现
+//
+//	m := metrics.New()
+//	bot, err := chatbot.New(host, token, chatbot.WithMetrics(m))
+//	http.Handle("/metrics", m)
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chatrbot/chatbot-go"
+)
+
+// 耗时直方图的默认分桶,单位秒
+var defaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// Collector 收集机器人运行指标,同时实现了http.Handler用于暴露给Prometheus抓取
+type Collector struct {
+	mu         sync.Mutex
+	counters   map[string]*counterVec
+	histograms map[string]*histogramVec
+	connected  bool
+}
+
+var _ chatbot.Metrics = new(Collector)
+
+// New 新建一个指标收集器
+func New() *Collector {
+	c := &Collector{
+		counters:   make(map[string]*counterVec),
+		histograms: make(map[string]*histogramVec),
+	}
+	c.counter("chatbot_ws_connects_total", "WebSocket连接成功次数")
+	c.counter("chatbot_ws_disconnects_total", "WebSocket连接断开次数")
+	c.counter("chatbot_ws_reconnect_attempts_total", "WebSocket重连尝试次数", "result")
+	c.counter("chatbot_messages_received_total", "收到的推送消息数", "push_type", "msg_type")
+	c.counter("chatbot_plugin_errors_total", "插件处理消息出错次数", "plugin")
+	c.histogram("chatbot_plugin_duration_seconds", "插件处理消息耗时", "plugin")
+	c.counter("chatbot_api_requests_total", "机器人http接口调用次数", "path", "code")
+	c.histogram("chatbot_api_request_duration_seconds", "机器人http接口调用耗时", "path")
+	return c
+}
+
+func (c *Collector) WsConnected() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.connected = true
+	c.counters["chatbot_ws_connects_total"].add(1)
+}
+
+func (c *Collector) WsDisconnected() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.connected = false
+	c.counters["chatbot_ws_disconnects_total"].add(1)
+}
+
+func (c *Collector) WsReconnectAttempt(err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counters["chatbot_ws_reconnect_attempts_total"].add(1, result)
+}
+
+func (c *Collector) MessageReceived(pushType chatbot.PushMsgType, msgType int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counters["chatbot_messages_received_total"].add(1,
+		strconv.Itoa(int(pushType)), strconv.Itoa(msgType))
+}
+
+func (c *Collector) PluginHandled(name string, duration time.Duration, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.histograms["chatbot_plugin_duration_seconds"].observe(duration.Seconds(), name)
+	if err != nil {
+		c.counters["chatbot_plugin_errors_total"].add(1, name)
+	}
+}
+
+func (c *Collector) APIRequested(path string, duration time.Duration, code int64, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.histograms["chatbot_api_request_duration_seconds"].observe(duration.Seconds(), path)
+	c.counters["chatbot_api_requests_total"].add(1, path, strconv.FormatInt(code, 10))
+}
+
+// ServeHTTP 以Prometheus文本格式输出所有指标
+func (c *Collector) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = c.Write(w)
+}
+
+// Write 以Prometheus文本格式输出所有指标
+func (c *Collector) Write(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	connected := 0
+	if c.connected {
+		connected = 1
+	}
+	fmt.Fprintln(w, "# HELP chatbot_ws_connected WebSocket当前是否已连接")
+	fmt.Fprintln(w, "# TYPE chatbot_ws_connected gauge")
+	fmt.Fprintln(w, "chatbot_ws_connected", connected)
+
+	for _, name := range c.counterNames() {
+		if err := c.counters[name].write(w, name); err != nil {
+			return err
+		}
+	}
+	for _, name := range c.histogramNames() {
+		if err := c.histograms[name].write(w, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Collector) counter(name, help string, labels ...string) {
+	c.counters[name] = &counterVec{help: help, labels: labels, values: make(map[string]float64)}
+}
+
+func (c *Collector) histogram(name, help string, labels ...string) {
+	c.histograms[name] = &histogramVec{help: help, labels: labels, values: make(map[string]*histogram)}
+}
+
+// counterVec 带标签的计数器
+type counterVec struct {
+	help   string
+	labels []string
+	values map[string]float64
+}
+
+func (cv *counterVec) add(v float64, labelValues ...string) {
+	cv.values[labelKey(labelValues)] += v
+}
+
+func (cv *counterVec) write(w io.Writer, name string) error {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, cv.help, name)
+	if len(cv.labels) == 0 && len(cv.values) == 0 {
+		_, err := fmt.Fprintln(w, name, 0)
+		return err
+	}
+	for _, key := range cv.keys() {
+		if _, err := fmt.Fprintf(w, "%s%s %s\n", name,
+			formatLabels(cv.labels, splitKey(key)), formatFloat(cv.values[key])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// histogramVec 带标签的直方图
+type histogramVec struct {
+	help   string
+	labels []string
+	values map[string]*histogram
+}
+
+type histogram struct {
+	buckets []uint64
+	count   uint64
+	sum     float64
+}
+
+func (hv *histogramVec) observe(v float64, labelValues ...string) {
+	key := labelKey(labelValues)
+	h, ok := hv.values[key]
+	if !ok {
+		h = &histogram{buckets: make([]uint64, len(defaultBuckets))}
+		hv.values[key] = h
+	}
+	for i, upper := range defaultBuckets {
+		if v <= upper {
+			h.buckets[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+func (hv *histogramVec) write(w io.Writer, name string) error {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", name, hv.help, name)
+	for _, key := range hv.keys() {
+		h := hv.values[key]
+		values := splitKey(key)
+		bucketLabels := append(append([]string(nil), hv.labels...), "le")
+		for i, upper := range defaultBuckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", name,
+				formatLabels(bucketLabels, append(append([]string(nil), values...), formatFloat(upper))), h.buckets[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", name,
+			formatLabels(bucketLabels, append(append([]string(nil), values...), "+Inf")), h.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", name, formatLabels(hv.labels, values), formatFloat(h.sum))
+		if _, err := fmt.Fprintf(w, "%s_count%s %d\n", name, formatLabels(hv.labels, values), h.count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 标签值之间的分隔符,不会出现在正常的标签值中
+const keySep = "\xff"
+
+func labelKey(values []string) string {
+	return strings.Join(values, keySep)
+}
+
+func splitKey(key string) []string {
+	if key == "" {
+		return nil
+	}
+	return strings.Split(key, keySep)
+}
+
+// labelEscaper 按Prometheus文本格式转义标签值,只处理反斜杠、双引号和换行
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(names))
+	for i, name := range names {
+		v := ""
+		if i < len(values) {
+			v = values[i]
+		}
+		pairs = append(pairs, name+`="`+labelEscaper.Replace(v)+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func (c *Collector) counterNames() []string {
+	keys := make([]string, 0, len(c.counters))
+	for k := range c.counters {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (c *Collector) histogramNames() []string {
+	keys := make([]string, 0, len(c.histograms))
+	for k := range c.histograms {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (cv *counterVec) keys() []string {
+	keys := make([]string, 0, len(cv.values))
+	for k := range cv.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (hv *histogramVec) keys() []string {
+	keys := make([]string, 0, len(hv.values))
+	for k := range hv.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
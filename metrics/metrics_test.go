@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chatrbot/chatbot-go"
+)
+
+func TestCollector_Write(t *testing.T) {
+	c := New()
+	c.WsConnected()
+	c.MessageReceived(chatbot.CusMsgTypeUser, chatbot.MsgTypeText)
+	c.PluginHandled("repeat", 20*time.Millisecond, errors.New("boom"))
+	c.APIRequested("/api/v1/chat/sendText", time.Second, 0, nil)
+
+	var buf bytes.Buffer
+	if err := c.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"chatbot_ws_connected 1",
+		"chatbot_ws_connects_total 1",
+		`chatbot_messages_received_total{push_type="10000",msg_type="1"} 1`,
+		`chatbot_plugin_errors_total{plugin="repeat"} 1`,
+		`chatbot_plugin_duration_seconds_bucket{plugin="repeat",le="0.025"} 1`,
+		`chatbot_api_requests_total{path="/api/v1/chat/sendText",code="0"} 1`,
+		`chatbot_api_request_duration_seconds_count{path="/api/v1/chat/sendText"} 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestFormatLabels(t *testing.T) {
+	got := formatLabels([]string{"plugin", "path"}, []string{"天气", "a\\b\"c\nd"})
+	want := `{plugin="天气",path="a\\b\"c\nd"}`
+	if got != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+}
@@ -0,0 +1,106 @@
+package chatbot
+
+import "net/http"
+
+// Option New方法的可选配置
+type Option func(*options)
+
+type options struct {
+	metrics      Metrics
+	tracer       Tracer
+	fileServer   *FileServer
+	mediaCache   *MediaCache
+	voiceEncoder VoiceEncoder
+	rateLimiter  *RateLimiter
+	httpClient   *http.Client
+
+	permissionCodes []int64
+
+	thumbnailProvider ThumbnailProvider
+	defaultThumb      string
+}
+
+func defaultOptions() *options {
+	return &options{
+		metrics: nopMetrics{},
+		tracer:  nopTracer{},
+	}
+}
+
+// WithMetrics 设置运行指标收集
+func WithMetrics(m Metrics) Option {
+	return func(o *options) {
+		if m != nil {
+			o.metrics = m
+		}
+	}
+}
+
+// WithTracer 设置链路追踪
+func WithTracer(t Tracer) Option {
+	return func(o *options) {
+		if t != nil {
+			o.tracer = t
+		}
+	}
+}
+
+// WithFileServer 设置临时文件服务,用于SendPicBytes、SendPicFile等发送本地数据的方法
+// 文件服务需要自行调用ListenAndServe启动,或者挂载到已有的http服务上
+func WithFileServer(fs *FileServer) Option {
+	return func(o *options) {
+		o.fileServer = fs
+	}
+}
+
+// WithMediaCache 设置OpenMedia使用的本地缓存
+func WithMediaCache(c *MediaCache) Option {
+	return func(o *options) {
+		o.mediaCache = c
+	}
+}
+
+// WithVoiceEncoder 设置WAV转silk的编码器,设置后发送语音的方法可以直接使用WAV
+func WithVoiceEncoder(enc VoiceEncoder) Option {
+	return func(o *options) {
+		o.voiceEncoder = enc
+	}
+}
+
+// WithThumbnailProvider 设置视频封面提供者,发送视频没有封面时使用
+func WithThumbnailProvider(p ThumbnailProvider) Option {
+	return func(o *options) {
+		o.thumbnailProvider = p
+	}
+}
+
+// WithDefaultThumb 设置默认的视频封面地址,发送视频没有封面时使用
+func WithDefaultThumb(thumbUrl string) Option {
+	return func(o *options) {
+		o.defaultThumb = thumbUrl
+	}
+}
+
+// WithRateLimiter 限制所有发送消息接口的频率,Broadcast也会使用该限流
+func WithRateLimiter(l *RateLimiter) Option {
+	return func(o *options) {
+		o.rateLimiter = l
+	}
+}
+
+// WithHTTPClient 设置下载媒体文件等外部请求使用的http客户端
+// 默认使用超时时间为5分钟的客户端
+func WithHTTPClient(c *http.Client) Option {
+	return func(o *options) {
+		o.httpClient = c
+	}
+}
+
+// WithPermissionCodes 设置服务端表示权限不足的业务码
+// 服务端文档没有约定权限不足时的业务码,默认只把http状态码403识别为ErrPermissionDenied,
+// 服务端用业务码表示时通过该配置指定,之后群管理接口返回这些业务码时会转换为PermissionError
+func WithPermissionCodes(codes ...int64) Option {
+	return func(o *options) {
+		o.permissionCodes = append(o.permissionCodes, codes...)
+	}
+}
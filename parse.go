@@ -0,0 +1,309 @@
+package chatbot
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+// ImageInfo 图片消息xml中的图片信息
+type ImageInfo struct {
+	Md5            string // 原图md5,可用于去重
+	Length         int64  // 原图大小,单位字节
+	HdLength       int64  // 高清图大小
+	AesKey         string // cdn文件的解密key
+	ThumbUrl       string // 缩略图cdn地址
+	ThumbAesKey    string // 缩略图解密key
+	ThumbLength    int64  // 缩略图大小
+	ThumbWidth     int    // 缩略图宽度
+	ThumbHeight    int    // 缩略图高度
+	MidUrl         string // 中图cdn地址
+	MidWidth       int    // 中图宽度
+	MidHeight      int    // 中图高度
+	HdUrl          string // 高清图cdn地址
+	HdWidth        int    // 高清图宽度
+	HdHeight       int    // 高清图高度
+	EncryptVersion int    // 加密版本
+}
+
+// ParseImageXML 解析图片消息的xml
+// 群消息使用GroupContent,私聊消息使用Content
+func ParseImageXML(xml string) (*ImageInfo, error) {
+	e, err := findElement(xml, "//img")
+	if err != nil {
+		return nil, err
+	}
+	return &ImageInfo{
+		Md5:            attr(e, "md5"),
+		Length:         attrInt(e, "length"),
+		HdLength:       attrInt(e, "hdlength"),
+		AesKey:         attr(e, "aeskey"),
+		ThumbUrl:       attr(e, "cdnthumburl"),
+		ThumbAesKey:    attr(e, "cdnthumbaeskey"),
+		ThumbLength:    attrInt(e, "cdnthumblength"),
+		ThumbWidth:     int(attrInt(e, "cdnthumbwidth")),
+		ThumbHeight:    int(attrInt(e, "cdnthumbheight")),
+		MidUrl:         attr(e, "cdnmidimgurl"),
+		MidWidth:       int(attrInt(e, "cdnmidwidth")),
+		MidHeight:      int(attrInt(e, "cdnmidheight")),
+		HdUrl:          attr(e, "cdnbigimgurl"),
+		HdWidth:        int(attrInt(e, "cdnhdwidth")),
+		HdHeight:       int(attrInt(e, "cdnhdheight")),
+		EncryptVersion: int(attrInt(e, "encryver")),
+	}, nil
+}
+
+// VoiceInfo 语音消息xml中的语音信息
+type VoiceInfo struct {
+	VoiceLength int64  // 语音时长,单位毫秒
+	Length      int64  // 文件大小,单位字节
+	VoiceFormat int    // 语音格式,4为silk
+	BufID       string // 语音buf id
+	AesKey      string // cdn文件的解密key
+	VoiceUrl    string // 语音cdn地址
+	FromUser    string // 发送人微信号
+	ClientMsgID string // 客户端消息id
+	EndFlag     bool   // 是否为语音的最后一段
+}
+
+// ParseVoiceXML 解析语音消息的xml
+func ParseVoiceXML(xml string) (*VoiceInfo, error) {
+	e, err := findElement(xml, "//voicemsg")
+	if err != nil {
+		return nil, err
+	}
+	return &VoiceInfo{
+		VoiceLength: attrInt(e, "voicelength"),
+		Length:      attrInt(e, "length"),
+		VoiceFormat: int(attrInt(e, "voiceformat")),
+		BufID:       attr(e, "bufid"),
+		AesKey:      attr(e, "aeskey"),
+		VoiceUrl:    attr(e, "voiceurl"),
+		FromUser:    attr(e, "fromusername"),
+		ClientMsgID: attr(e, "clientmsgid"),
+		EndFlag:     attr(e, "endflag") == "1",
+	}, nil
+}
+
+// VideoInfo 视频消息xml中的视频信息
+type VideoInfo struct {
+	PlayLength  int64  // 视频时长,单位秒
+	Length      int64  // 视频大小,单位字节
+	Md5         string // 视频md5
+	NewMd5      string // 视频新版md5
+	AesKey      string // cdn文件的解密key
+	VideoUrl    string // 视频cdn地址
+	ThumbUrl    string // 封面cdn地址
+	ThumbAesKey string // 封面解密key
+	ThumbLength int64  // 封面大小
+	ThumbWidth  int    // 封面宽度
+	ThumbHeight int    // 封面高度
+	FromUser    string // 发送人微信号
+}
+
+// ParseVideoXML 解析视频消息的xml
+func ParseVideoXML(xml string) (*VideoInfo, error) {
+	e, err := findElement(xml, "//videomsg")
+	if err != nil {
+		return nil, err
+	}
+	return &VideoInfo{
+		PlayLength:  attrInt(e, "playlength"),
+		Length:      attrInt(e, "length"),
+		Md5:         attr(e, "md5"),
+		NewMd5:      attr(e, "newmd5"),
+		AesKey:      attr(e, "aeskey"),
+		VideoUrl:    attr(e, "cdnvideourl"),
+		ThumbUrl:    attr(e, "cdnthumburl"),
+		ThumbAesKey: attr(e, "cdnthumbaeskey"),
+		ThumbLength: attrInt(e, "cdnthumblength"),
+		ThumbWidth:  int(attrInt(e, "cdnthumbwidth")),
+		ThumbHeight: int(attrInt(e, "cdnthumbheight")),
+		FromUser:    attr(e, "fromusername"),
+	}, nil
+}
+
+// EmojiInfo 表情消息中的表情信息
+type EmojiInfo struct {
+	Md5    string // 表情md5,和Len一起用于发送
+	Len    int64  // 表情大小
+	CdnUrl string // 表情下载地址
+	Width  int
+	Height int
+	Type   int // 表情类型,2为静态图,3为动图
+}
+
+// ErrEmptyEmojiMd5 表情消息中没有md5,无法用于发送表情
+var ErrEmptyEmojiMd5 = errors.New("emoji md5 is empty")
+
+// ParseEmojiXML 解析表情消息的xml,缺少emoji元素时返回错误
+// 部分表情只有cdnurl没有md5,需要md5时调用方自行检查
+func ParseEmojiXML(xml string) (*EmojiInfo, error) {
+	e, err := findElement(xml, "//emoji")
+	if err != nil {
+		return nil, err
+	}
+	info := &EmojiInfo{
+		Md5:    attr(e, "md5"),
+		Len:    attrInt(e, "len"),
+		CdnUrl: attr(e, "cdnurl"),
+		Width:  int(attrInt(e, "width")),
+		Height: int(attrInt(e, "height")),
+		Type:   int(attrInt(e, "type")),
+	}
+	return info, nil
+}
+
+// LocationInfo 位置消息中的位置信息
+type LocationInfo struct {
+	Latitude  float64 // 纬度
+	Longitude float64 // 经度
+	Scale     int     // 地图缩放级别
+	Label     string  // 详细地址
+	PoiName   string  // 地点名称
+	PoiID     string  // 地点id
+}
+
+// ToSendRequest 转换为发送位置的请求
+func (l *LocationInfo) ToSendRequest(toUser string) *SendLocationRequest {
+	return &SendLocationRequest{
+		ToUser:    toUser,
+		Latitude:  l.Latitude,
+		Longitude: l.Longitude,
+		Scale:     l.Scale,
+		Label:     l.Label,
+		PoiName:   l.PoiName,
+	}
+}
+
+// ParseLocationXML 解析位置消息的xml
+func ParseLocationXML(xml string) (*LocationInfo, error) {
+	e, err := findElement(xml, "//location")
+	if err != nil {
+		return nil, err
+	}
+	l := &LocationInfo{
+		Scale:   int(attrInt(e, "scale")),
+		Label:   attr(e, "label"),
+		PoiName: attr(e, "poiname"),
+		PoiID:   attr(e, "poiid"),
+	}
+	l.Latitude, _ = strconv.ParseFloat(attr(e, "x"), 64)
+	l.Longitude, _ = strconv.ParseFloat(attr(e, "y"), 64)
+	return l, nil
+}
+
+// CardInfo 名片消息中的用户信息
+type CardInfo struct {
+	UserName        string // 微信号
+	NickName        string // 昵称
+	Alias           string // 自定义微信号
+	BigHeadImgUrl   string // 大头像地址
+	SmallHeadImgUrl string // 小头像地址
+	Sex             int    // 性别,1男2女
+	Province        string
+	City            string
+	Sign            string // 个性签名
+}
+
+// ParseCardXML 解析名片消息的xml
+func ParseCardXML(xml string) (*CardInfo, error) {
+	e, err := findElement(xml, "//msg")
+	if err != nil {
+		return nil, err
+	}
+	c := &CardInfo{
+		UserName:        attr(e, "username"),
+		NickName:        attr(e, "nickname"),
+		Alias:           attr(e, "alias"),
+		BigHeadImgUrl:   attr(e, "bigheadimgurl"),
+		SmallHeadImgUrl: attr(e, "smallheadimgurl"),
+		Sex:             int(attrInt(e, "sex")),
+		Province:        attr(e, "province"),
+		City:            attr(e, "city"),
+		Sign:            attr(e, "sign"),
+	}
+	if c.UserName == "" {
+		return nil, errors.New("card username is empty")
+	}
+	return c, nil
+}
+
+// AtAllUser @所有人时atuserlist中的微信号
+const AtAllUser = "notify@all"
+
+// MsgSource 消息的附加信息
+type MsgSource struct {
+	AtUserList  []string // 被@的人微信号
+	Silence     bool     // 群是否开启了消息免打扰
+	MemberCount int      // 群成员数量
+	Signature   string   // 消息签名
+}
+
+// AtAll 是否@了所有人
+func (s *MsgSource) AtAll() bool {
+	for _, u := range s.AtUserList {
+		if u == AtAllUser {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseMsgSource 解析UserMessage.MsgSource中的xml
+func ParseMsgSource(xml string) (*MsgSource, error) {
+	e, err := findElement(xml, "//msgsource")
+	if err != nil {
+		return nil, err
+	}
+	s := &MsgSource{
+		Silence:   childText(e, "silence") == "1",
+		Signature: childText(e, "signature"),
+	}
+	s.MemberCount, _ = strconv.Atoi(childText(e, "membercount"))
+	for _, u := range strings.Split(childText(e, "atuserlist"), ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			s.AtUserList = append(s.AtUserList, u)
+		}
+	}
+	return s, nil
+}
+
+// findElement 解析xml并查找指定元素,找不到时返回错误
+func findElement(xml, path string) (*etree.Element, error) {
+	doc := etree.NewDocument()
+	if err := doc.ReadFromString(strings.TrimSpace(xml)); err != nil {
+		return nil, err
+	}
+	e := doc.FindElement(path)
+	if e == nil {
+		return nil, fmt.Errorf("element %s not found", path)
+	}
+	return e, nil
+}
+
+// attr 获取元素属性,不存在时返回空字符串
+func attr(e *etree.Element, key string) string {
+	if e == nil {
+		return ""
+	}
+	return e.SelectAttrValue(key, "")
+}
+
+// attrInt 获取数字类型的元素属性,不存在或者格式错误时返回0
+func attrInt(e *etree.Element, key string) int64 {
+	v, _ := strconv.ParseInt(strings.TrimSpace(attr(e, key)), 10, 64)
+	return v
+}
+
+// childText 获取子元素的文本,不存在时返回空字符串
+func childText(e *etree.Element, tag string) string {
+	c := e.SelectElement(tag)
+	if c == nil {
+		return ""
+	}
+	return strings.TrimSpace(c.Text())
+}
@@ -0,0 +1,108 @@
+package chatbot
+
+import "testing"
+
+const testImageXML = `<?xml version="1.0"?>
+<msg>
+	<img aeskey="b4c6ee2f0d2a" encryver="1" cdnthumbaeskey="b4c6ee2f0d2a" cdnthumburl="3057020100044b30" cdnthumblength="3016" cdnthumbheight="120" cdnthumbwidth="67" cdnmidheight="0" cdnmidwidth="0" cdnhdheight="0" cdnhdwidth="0" cdnmidimgurl="3057020100044b31" length="25403" md5="a9d1c5d6e5e8f1c4b3a2" hdlength="102400" cdnbigimgurl="3057020100044b32" />
+</msg>`
+
+func TestParseImageXML(t *testing.T) {
+	info, err := ParseImageXML(testImageXML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Md5 != "a9d1c5d6e5e8f1c4b3a2" || info.Length != 25403 || info.HdLength != 102400 {
+		t.Errorf("unexpected md5/length: %+v", info)
+	}
+	if info.ThumbUrl != "3057020100044b30" || info.ThumbWidth != 67 || info.ThumbHeight != 120 {
+		t.Errorf("unexpected thumb: %+v", info)
+	}
+	if info.MidUrl != "3057020100044b31" || info.HdUrl != "3057020100044b32" || info.AesKey != "b4c6ee2f0d2a" {
+		t.Errorf("unexpected cdn info: %+v", info)
+	}
+
+	if _, err := ParseImageXML(`<msg></msg>`); err == nil {
+		t.Error("want error for xml without img")
+	}
+}
+
+func TestParseVoiceXML(t *testing.T) {
+	info, err := ParseVoiceXML(`<msg><voicemsg endflag="1" cancelflag="0" forwardflag="0" voiceformat="4" voicelength="2800" length="4557" bufid="0" aeskey="5f1e" voiceurl="3052020100" voicemd5="" clientmsgid="41a8" fromusername="wxid_a" /></msg>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.VoiceLength != 2800 || info.Length != 4557 || info.VoiceFormat != 4 || info.FromUser != "wxid_a" || !info.EndFlag {
+		t.Errorf("unexpected voice info: %+v", info)
+	}
+}
+
+func TestParseVideoXML(t *testing.T) {
+	info, err := ParseVideoXML(`<?xml version="1.0"?>
+<msg>
+	<videomsg aeskey="a1" cdnthumbaeskey="a2" cdnvideourl="v1" cdnthumburl="t1" length="1048576" playlength="12" cdnthumblength="5120" cdnthumbwidth="224" cdnthumbheight="398" fromusername="wxid_a" md5="m1" newmd5="m2" isad="0" />
+</msg>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.PlayLength != 12 || info.Length != 1048576 || info.Md5 != "m1" || info.VideoUrl != "v1" {
+		t.Errorf("unexpected video info: %+v", info)
+	}
+	if info.ThumbUrl != "t1" || info.ThumbLength != 5120 || info.ThumbWidth != 224 || info.ThumbHeight != 398 {
+		t.Errorf("unexpected video thumb: %+v", info)
+	}
+}
+
+func TestParseMsgSource(t *testing.T) {
+	xml := `<msgsource>
+	<atuserlist><![CDATA[,wxid_a,notify@all]]></atuserlist>
+	<silence>1</silence>
+	<membercount>120</membercount>
+	<signature><![CDATA[v1_abc]]></signature>
+</msgsource>`
+	s, err := ParseMsgSource(xml)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.AtUserList) != 2 || s.AtUserList[0] != "wxid_a" || !s.AtAll() {
+		t.Errorf("unexpected at user list: %v", s.AtUserList)
+	}
+	if !s.Silence || s.MemberCount != 120 || s.Signature != "v1_abc" {
+		t.Errorf("unexpected msg source: %+v", s)
+	}
+
+	msg := &UserMessage{MsgSource: xml}
+	if !msg.IsAtAll() || msg.MemberCount() != 120 || !msg.IsSilenced() {
+		t.Error("unexpected UserMessage accessors")
+	}
+	if (&UserMessage{}).MemberCount() != 0 {
+		t.Error("want 0 member count for empty msg source")
+	}
+}
+
+func TestParseLocationXML(t *testing.T) {
+	l, err := ParseLocationXML(`<?xml version="1.0"?>
+<msg>
+	<location x="39.908722" y="116.397499" scale="16" label="北京市东城区东长安街" maptype="0" poiname="天安门" poiid="qqmap_123" />
+</msg>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Latitude != 39.908722 || l.Longitude != 116.397499 || l.Scale != 16 || l.PoiName != "天安门" || l.PoiID != "qqmap_123" {
+		t.Errorf("unexpected location: %+v", l)
+	}
+	if req := l.ToSendRequest("wxid_a"); req.ToUser != "wxid_a" || req.Label != "北京市东城区东长安街" {
+		t.Errorf("unexpected send request: %+v", req)
+	}
+}
+
+func TestParseCardXML(t *testing.T) {
+	c, err := ParseCardXML(`<?xml version="1.0"?>
+<msg bigheadimgurl="http://wx.qlogo.cn/big" smallheadimgurl="http://wx.qlogo.cn/small" username="wxid_a" nickname="小明" alias="xiaoming" imagestatus="3" scene="17" province="北京" city="中国" sign="" sex="1" certflag="0" />`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.UserName != "wxid_a" || c.NickName != "小明" || c.Alias != "xiaoming" || c.Sex != 1 || c.BigHeadImgUrl != "http://wx.qlogo.cn/big" {
+		t.Errorf("unexpected card: %+v", c)
+	}
+}
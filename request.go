@@ -2,49 +2,96 @@ package chatbot
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/tidwall/gjson"
 )
 
 const (
-	defaultTimeOut     = time.Second * 30                     // 默认超时
-	urlSendText        = "/api/v1/chat/sendText"              // 发送文本
-	urlSendPic         = "/api/v1/chat/sendPic"               // 发送图片
-	urlSendEmoji       = "/api/v1/chat/sendEmoji"             // 发送表情
-	urlSendVideo       = "/api/v1/chat/sendVideo"             // 发送视频
-	urlSendVoice       = "/api/v1/chat/sendVoice"             // 发送语音
-	urlSendMiniProgram = "/api/v1/chat/sendSmallApp"          // 发送语音
-	urlDownloadImage   = "/api/v1/chat/downloadImage"         // 下载图片
-	urlDownloadVideo   = "/api/v1/chat/downloadVideo"         // 下载视频
-	urlDownloadVoice   = "/api/v1/chat/downloadVoice"         // 下载音频
-	urlDelGroupMember  = "/api/v1/chatroom/delChatRoomMember" // 删除群成员
+	defaultTimeOut     = time.Second * 30                           // 默认超时
+	urlSendPrefix      = "/api/v1/chat/send"                        // 发送消息接口的前缀
+	urlSendText        = "/api/v1/chat/sendText"                    // 发送文本
+	urlSendPic         = "/api/v1/chat/sendPic"                     // 发送图片
+	urlSendEmoji       = "/api/v1/chat/sendEmoji"                   // 发送表情
+	urlSendVideo       = "/api/v1/chat/sendVideo"                   // 发送视频
+	urlSendVoice       = "/api/v1/chat/sendVoice"                   // 发送语音
+	urlSendMiniProgram = "/api/v1/chat/sendSmallApp"                // 发送小程序
+	urlSendQuote       = "/api/v1/chat/sendQuote"                   // 发送引用回复
+	urlSendLink        = "/api/v1/chat/sendLink"                    // 发送链接卡片
+	urlSendCard        = "/api/v1/chat/sendCard"                    // 发送名片
+	urlSendLocation    = "/api/v1/chat/sendLocation"                // 发送位置
+	urlDownloadImage   = "/api/v1/chat/downloadImage"               // 下载图片
+	urlDownloadVideo   = "/api/v1/chat/downloadVideo"               // 下载视频
+	urlDownloadVoice   = "/api/v1/chat/downloadVoice"               // 下载音频
+	urlDelGroupMember  = "/api/v1/chatroom/delChatRoomMember"       // 删除群成员
+	urlAddGroupMember  = "/api/v1/chatroom/addChatRoomMember"       // 直接拉人进群
+	urlInviteGroup     = "/api/v1/chatroom/inviteChatRoomMember"    // 邀请进群
+	urlGroupMembers    = "/api/v1/chatroom/getChatRoomMemberList"   // 获取群成员
+	urlSetGroupName    = "/api/v1/chatroom/setChatRoomName"         // 修改群名称
+	urlSetGroupNotice  = "/api/v1/chatroom/setChatRoomAnnouncement" // 修改群公告
+	urlTransferOwner   = "/api/v1/chatroom/transferChatRoomOwner"   // 转让群主
+	urlAddGroupAdmin   = "/api/v1/chatroom/addChatRoomAdmin"        // 设置群管理员
+	urlDelGroupAdmin   = "/api/v1/chatroom/delChatRoomAdmin"        // 取消群管理员
 )
 
 // BotServer 调用机器人http接口的服务
 // 主要用于基本的消息发送
 type BotServer struct {
-	host  string
-	token string
+	host    string
+	token   string
+	metrics Metrics
+	tracer  Tracer
+	limiter *RateLimiter
+	// permissionCodes 表示权限不足的业务码
+	permissionCodes []int64
+
+	// 运行时探测到的可选接口支持情况
+	caps capabilities
 }
 
-func newBotServer(host, token string) *BotServer {
+func newBotServer(host, token string, o *options) *BotServer {
 	return &BotServer{
-		host:  host,
-		token: token,
+		host:    host,
+		token:   token,
+		metrics: o.metrics,
+		tracer:  o.tracer,
+		limiter: o.rateLimiter,
+
+		permissionCodes: o.permissionCodes,
 	}
 }
 
 // baseRequest 拼接请求
 // 接口都为post,token需要在url中携带
-func (bs *BotServer) baseRequest(addr string, body []byte, duration time.Duration, APIRsp interface{}) error {
+// 可选接口已知不支持时直接返回UnsupportedError,参考Feature
+func (bs *BotServer) baseRequest(ctx context.Context, addr string, body []byte, duration time.Duration, APIRsp interface{}) (err error) {
+	if err := bs.caps.check(addr); err != nil {
+		return err
+	}
+	// 只限制发送消息的接口
+	if bs.limiter != nil && strings.HasPrefix(addr, urlSendPrefix) {
+		if err := bs.limiter.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	ctx, span := bs.tracer.Start(ctx, "chatbot.api", Attr(AttrEndpoint, addr))
+	start := time.Now()
+	defer func() {
+		bs.metrics.APIRequested(addr, time.Since(start), errorCode(err), err)
+		if err != nil {
+			span.RecordError(err)
+		}
+		span.End()
+	}()
+
 	u := url.URL{
 		Scheme: "http",
 		Host:   bs.host,
@@ -53,7 +100,7 @@ func (bs *BotServer) baseRequest(addr string, body []byte, duration time.Duratio
 	query, _ := url.ParseQuery("token=" + bs.token)
 	u.RawQuery = query.Encode()
 	log.Println("request:", u.String())
-	req, err := http.NewRequest(http.MethodPost, u.String(), bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(body))
 	if err != nil {
 		return err
 	}
@@ -65,7 +112,8 @@ func (bs *BotServer) baseRequest(addr string, body []byte, duration time.Duratio
 		return err
 	}
 	if rsp.StatusCode != http.StatusOK {
-		return errors.New(fmt.Sprintf("response status code err:%d", rsp.StatusCode))
+		_ = rsp.Body.Close()
+		return bs.caps.observe(addr, &StatusError{StatusCode: rsp.StatusCode})
 	}
 
 	rspBody, err := ioutil.ReadAll(rsp.Body)
@@ -78,12 +126,16 @@ func (bs *BotServer) baseRequest(addr string, body []byte, duration time.Duratio
 	}
 	bodyJson := gjson.ParseBytes(rspBody)
 	rspData := bodyJson.Get("data").String()
-	success := bodyJson.Get("code").Int() == 0
+	code := bodyJson.Get("code").Int()
 	message := bodyJson.Get("msg").String()
-	if success {
+	if code == 0 {
+		// 部分接口成功时不返回data
+		if rspData == "" || rspData == "null" {
+			return nil
+		}
 		return json.Unmarshal([]byte(rspData), APIRsp)
 	}
-	return errors.New(message)
+	return &APIError{Code: code, Msg: message}
 }
 
 func (bs *BotServer) toJson(req interface{}) []byte {
@@ -92,71 +144,155 @@ func (bs *BotServer) toJson(req interface{}) []byte {
 }
 
 // sendTextMessage 发送文本消息
-func (bs *BotServer) sendTextMessage(req *SendTextRequest) (*SendTextResponse, error) {
+func (bs *BotServer) sendTextMessage(ctx context.Context, req *SendTextRequest) (*SendTextResponse, error) {
 	rsp := &SendTextResponse{}
-	err := bs.baseRequest(urlSendText, bs.toJson(req), defaultTimeOut, rsp)
+	err := bs.baseRequest(ctx, urlSendText, bs.toJson(req), defaultTimeOut, rsp)
 	return rsp, err
 }
 
 // sendEmojiMessage 发送图片
-func (bs *BotServer) sendPicMessage(req *SendPicRequest) (*SendPicResponse, error) {
+func (bs *BotServer) sendPicMessage(ctx context.Context, req *SendPicRequest) (*SendPicResponse, error) {
 	rsp := &SendPicResponse{}
-	err := bs.baseRequest(urlSendPic, bs.toJson(req), defaultTimeOut, rsp)
+	err := bs.baseRequest(ctx, urlSendPic, bs.toJson(req), defaultTimeOut, rsp)
 	return rsp, err
 }
 
 // sendEmojiMessage 发送表情
-func (bs *BotServer) sendEmojiMessage(req *SendEmojiRequest) (*SendEmojiResponse, error) {
+func (bs *BotServer) sendEmojiMessage(ctx context.Context, req *SendEmojiRequest) (*SendEmojiResponse, error) {
 	rsp := &SendEmojiResponse{}
-	err := bs.baseRequest(urlSendEmoji, bs.toJson(req), defaultTimeOut, rsp)
+	err := bs.baseRequest(ctx, urlSendEmoji, bs.toJson(req), defaultTimeOut, rsp)
 	return rsp, err
 }
 
 // sendVideoMessage 发送视频
-func (bs *BotServer) sendVideoMessage(req *SendVideoRequest) (*SendVideoResponse, error) {
+func (bs *BotServer) sendVideoMessage(ctx context.Context, req *SendVideoRequest) (*SendVideoResponse, error) {
 	rsp := &SendVideoResponse{}
-	err := bs.baseRequest(urlSendVideo, bs.toJson(req), defaultTimeOut, rsp)
+	err := bs.baseRequest(ctx, urlSendVideo, bs.toJson(req), defaultTimeOut, rsp)
 	return rsp, err
 }
 
 // sendVoiceMessage 发送音频
-func (bs *BotServer) sendVoiceMessage(req *SendVoiceRequest) (*SendVoiceResponse, error) {
+func (bs *BotServer) sendVoiceMessage(ctx context.Context, req *SendVoiceRequest) (*SendVoiceResponse, error) {
 	rsp := &SendVoiceResponse{}
-	err := bs.baseRequest(urlSendVoice, bs.toJson(req), defaultTimeOut, rsp)
+	err := bs.baseRequest(ctx, urlSendVoice, bs.toJson(req), defaultTimeOut, rsp)
 	return rsp, err
 }
 
 // sendMiniProgramMessage 发送小程序
-func (bs *BotServer) sendMiniProgramMessage(req *SendMiniProgramRequest) (*SendMiniProgramResponse, error) {
+func (bs *BotServer) sendMiniProgramMessage(ctx context.Context, req *SendMiniProgramRequest) (*SendMiniProgramResponse, error) {
 	rsp := &SendMiniProgramResponse{}
-	err := bs.baseRequest(urlSendMiniProgram, bs.toJson(req), defaultTimeOut, rsp)
+	err := bs.baseRequest(ctx, urlSendMiniProgram, bs.toJson(req), defaultTimeOut, rsp)
+	return rsp, err
+}
+
+// sendQuoteMessage 发送引用回复
+func (bs *BotServer) sendQuoteMessage(ctx context.Context, req *SendQuoteRequest) (*SendQuoteResponse, error) {
+	rsp := &SendQuoteResponse{}
+	err := bs.baseRequest(ctx, urlSendQuote, bs.toJson(req), defaultTimeOut, rsp)
+	return rsp, err
+}
+
+// sendLinkMessage 发送链接卡片
+func (bs *BotServer) sendLinkMessage(ctx context.Context, req *SendLinkRequest) (*SendLinkResponse, error) {
+	rsp := &SendLinkResponse{}
+	err := bs.baseRequest(ctx, urlSendLink, bs.toJson(req), defaultTimeOut, rsp)
+	return rsp, err
+}
+
+// sendCardMessage 发送名片
+func (bs *BotServer) sendCardMessage(ctx context.Context, req *SendCardRequest) (*SendCardResponse, error) {
+	rsp := &SendCardResponse{}
+	err := bs.baseRequest(ctx, urlSendCard, bs.toJson(req), defaultTimeOut, rsp)
+	return rsp, err
+}
+
+// sendLocationMessage 发送位置
+func (bs *BotServer) sendLocationMessage(ctx context.Context, req *SendLocationRequest) (*SendLocationResponse, error) {
+	rsp := &SendLocationResponse{}
+	err := bs.baseRequest(ctx, urlSendLocation, bs.toJson(req), defaultTimeOut, rsp)
 	return rsp, err
 }
 
 // downloadPic 下载图片消息的图片
-func (bs *BotServer) downloadPic(req *DownloadImageRequest) (*DownloadImageResponse, error) {
+func (bs *BotServer) downloadPic(ctx context.Context, req *DownloadImageRequest) (*DownloadImageResponse, error) {
 	rsp := &DownloadImageResponse{}
-	err := bs.baseRequest(urlDownloadImage, bs.toJson(req), defaultTimeOut, rsp)
+	err := bs.baseRequest(ctx, urlDownloadImage, bs.toJson(req), defaultTimeOut, rsp)
 	return rsp, err
 }
 
 // downloadPic 下载视频消息的视频
-func (bs *BotServer) downloadVideo(req *DownloadVideoRequest) (*DownloadVideoResponse, error) {
+func (bs *BotServer) downloadVideo(ctx context.Context, req *DownloadVideoRequest) (*DownloadVideoResponse, error) {
 	rsp := &DownloadVideoResponse{}
-	err := bs.baseRequest(urlDownloadVideo, bs.toJson(req), defaultTimeOut, rsp)
+	err := bs.baseRequest(ctx, urlDownloadVideo, bs.toJson(req), defaultTimeOut, rsp)
 	return rsp, err
 }
 
 // downloadPic 下载语音消息的语音
-func (bs *BotServer) downloadVoice(req *DownloadVoiceRequest) (*DownloadVoiceResponse, error) {
+func (bs *BotServer) downloadVoice(ctx context.Context, req *DownloadVoiceRequest) (*DownloadVoiceResponse, error) {
 	rsp := &DownloadVoiceResponse{}
-	err := bs.baseRequest(urlDownloadVoice, bs.toJson(req), defaultTimeOut, rsp)
+	err := bs.baseRequest(ctx, urlDownloadVoice, bs.toJson(req), defaultTimeOut, rsp)
 	return rsp, err
 }
 
 // DelGroupRequest 踢出群用户
-func (bs *BotServer) delGroupMembers(req *DelGroupRequest) (*DelGroupResponse, error) {
+func (bs *BotServer) delGroupMembers(ctx context.Context, req *DelGroupRequest) (*DelGroupResponse, error) {
 	rsp := &DelGroupResponse{}
-	err := bs.baseRequest(urlDelGroupMember, bs.toJson(req), defaultTimeOut, rsp)
+	err := bs.baseRequest(ctx, urlDelGroupMember, bs.toJson(req), defaultTimeOut, rsp)
+	return rsp, err
+}
+
+// addGroupMembers 直接拉人进群
+func (bs *BotServer) addGroupMembers(ctx context.Context, req *AddGroupRequest) (*AddGroupResponse, error) {
+	rsp := &AddGroupResponse{}
+	err := bs.baseRequest(ctx, urlAddGroupMember, bs.toJson(req), defaultTimeOut, rsp)
+	return rsp, err
+}
+
+// inviteGroupMembers 发送群邀请
+func (bs *BotServer) inviteGroupMembers(ctx context.Context, req *InviteGroupRequest) (*InviteGroupResponse, error) {
+	rsp := &InviteGroupResponse{}
+	err := bs.baseRequest(ctx, urlInviteGroup, bs.toJson(req), defaultTimeOut, rsp)
+	return rsp, err
+}
+
+// getGroupMembers 获取群成员列表
+func (bs *BotServer) getGroupMembers(ctx context.Context, req *GetGroupMembersRequest) (*GetGroupMembersResponse, error) {
+	rsp := &GetGroupMembersResponse{}
+	err := bs.baseRequest(ctx, urlGroupMembers, bs.toJson(req), defaultTimeOut, rsp)
+	return rsp, err
+}
+
+// setGroupName 修改群名称
+func (bs *BotServer) setGroupName(ctx context.Context, req *SetGroupNameRequest) (*SetGroupNameResponse, error) {
+	rsp := &SetGroupNameResponse{}
+	err := bs.baseRequest(ctx, urlSetGroupName, bs.toJson(req), defaultTimeOut, rsp)
+	return rsp, err
+}
+
+// setGroupAnnouncement 修改群公告
+func (bs *BotServer) setGroupAnnouncement(ctx context.Context, req *SetGroupAnnouncementRequest) (*SetGroupAnnouncementResponse, error) {
+	rsp := &SetGroupAnnouncementResponse{}
+	err := bs.baseRequest(ctx, urlSetGroupNotice, bs.toJson(req), defaultTimeOut, rsp)
+	return rsp, err
+}
+
+// transferGroupOwner 转让群主
+func (bs *BotServer) transferGroupOwner(ctx context.Context, req *TransferGroupOwnerRequest) (*TransferGroupOwnerResponse, error) {
+	rsp := &TransferGroupOwnerResponse{}
+	err := bs.baseRequest(ctx, urlTransferOwner, bs.toJson(req), defaultTimeOut, rsp)
+	return rsp, err
+}
+
+// addGroupAdmins 设置群管理员
+func (bs *BotServer) addGroupAdmins(ctx context.Context, req *SetGroupAdminRequest) (*SetGroupAdminResponse, error) {
+	rsp := &SetGroupAdminResponse{}
+	err := bs.baseRequest(ctx, urlAddGroupAdmin, bs.toJson(req), defaultTimeOut, rsp)
+	return rsp, err
+}
+
+// delGroupAdmins 取消群管理员
+func (bs *BotServer) delGroupAdmins(ctx context.Context, req *SetGroupAdminRequest) (*SetGroupAdminResponse, error) {
+	rsp := &SetGroupAdminResponse{}
+	err := bs.baseRequest(ctx, urlDelGroupAdmin, bs.toJson(req), defaultTimeOut, rsp)
 	return rsp, err
 }
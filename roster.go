@@ -0,0 +1,510 @@
+package chatbot
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Contact 花名册中的成员
+type Contact struct {
+	UserName    string    `json:"userName"`    // 微信号
+	NickName    string    `json:"nickName"`    // 微信昵称
+	DisplayName string    `json:"displayName"` // 群昵称
+	HeadImg     string    `json:"headImg"`     // 头像
+	Role        int8      `json:"role"`        // 群内身份,1成员,2管理员,3群主,未知时为0
+	UpdatedAt   time.Time `json:"updatedAt"`   // 最后一次更新时间
+}
+
+func (c *Contact) IsAdmin() bool {
+	return c.Role == RoleAdmin
+}
+
+func (c *Contact) IsGroupOwner() bool {
+	return c.Role == RoleOwner
+}
+
+// Name 群内显示的名称,优先使用群昵称
+func (c *Contact) Name() string {
+	if c.DisplayName != "" {
+		return c.DisplayName
+	}
+	return c.NickName
+}
+
+// GroupRoster 一个群的花名册,Group为空时表示私聊联系人
+type GroupRoster struct {
+	Group     string     `json:"group"`
+	Name      string     `json:"name"`
+	Members   []*Contact `json:"members"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+}
+
+// RosterStore 花名册的持久化接口
+// Save和Delete在成员变动时调用,每次传入整个群的花名册
+type RosterStore interface {
+	Load() ([]*GroupRoster, error)
+	Save(g *GroupRoster) error
+	Delete(group string) error
+}
+
+// Roster 群成员花名册
+// 作为插件使用时会从收到的消息和群事件中记录成员的昵称和身份
+// 可以在消息之外查询成员,例如欢迎新人或者@管理员
+//
+//	roster, _ := chatbot.NewRoster(chatbot.NewFileRosterStore("roster.json"))
+//	bot.Use(roster)
+//	admins := roster.Admins("123@chatroom")
+type Roster struct {
+	store RosterStore
+	// saveMu 保证按修改的顺序写入store,需要在持有mu时获取
+	saveMu sync.Mutex
+
+	mu     sync.RWMutex
+	groups map[string]*rosterGroup
+}
+
+type rosterGroup struct {
+	name      string
+	members   map[string]*Contact
+	updatedAt time.Time
+}
+
+var _ Plugin = new(Roster)
+
+// NewRoster 新建花名册,store为nil时只保存在内存中
+func NewRoster(store RosterStore) (*Roster, error) {
+	r := &Roster{store: store, groups: make(map[string]*rosterGroup)}
+	if store == nil {
+		return r, nil
+	}
+	list, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range list {
+		rg := r.group(g.Group)
+		rg.name = g.Name
+		rg.updatedAt = g.UpdatedAt
+		for _, c := range g.Members {
+			rg.members[c.UserName] = c
+		}
+	}
+	return r, nil
+}
+
+func (r *Roster) Name() string {
+	return "roster"
+}
+
+func (r *Roster) Do(msg *PushMessage) error {
+	switch msg.MsgType {
+	case CusMsgTypeUser:
+		m := &UserMessage{}
+		if err := json.Unmarshal(msg.Data, m); err != nil {
+			return err
+		}
+		return r.Observe(m)
+	case CusMsgTypeGroupEvent:
+		e := &GroupBotEvent{}
+		if err := json.Unmarshal(msg.Data, e); err != nil {
+			return err
+		}
+		return r.ObserveEvent(e)
+	}
+	return nil
+}
+
+// Observe 从用户消息中记录发言人
+func (r *Roster) Observe(msg *UserMessage) error {
+	if msg.MsgType == MsgTypeSys || msg.MsgType == MsgTypeSysNotice {
+		return nil
+	}
+	if !IsGroupMessage(msg.FromUser) {
+		if msg.FromUser == "" || msg.FromUser == msg.ClientUserName {
+			return nil
+		}
+		return r.upsert("", &Contact{UserName: msg.FromUser})
+	}
+	if msg.GroupMember == "" {
+		return nil
+	}
+	return r.upsert(msg.FromUser, &Contact{
+		UserName: msg.GroupMember,
+		NickName: msg.GroupMemberNickname,
+		Role:     msg.GroupMemberRole,
+	})
+}
+
+// ObserveEvent 根据群事件更新花名册
+func (r *Roster) ObserveEvent(e *GroupBotEvent) error {
+	group := e.Group.GroupUserName
+	if group == "" {
+		return nil
+	}
+	switch e.Event {
+	case GroupEventKicked:
+		return r.Forget(group)
+	case GroupEventMemberQuit:
+		r.mu.Lock()
+		rg := r.group(group)
+		for _, m := range e.Members {
+			delete(rg.members, m.UserName)
+		}
+		r.touch(rg, e.Group.GroupNickName)
+		return r.saveAndUnlock(group)
+	case GroupEventNewMember, GroupEventInvited:
+		r.mu.Lock()
+		rg := r.group(group)
+		for _, m := range e.Members {
+			r.merge(rg, &Contact{UserName: m.UserName, NickName: m.NickName, HeadImg: m.HeadImg})
+		}
+		r.touch(rg, e.Group.GroupNickName)
+		return r.saveAndUnlock(group)
+	case GroupEventRenamed:
+		r.mu.Lock()
+		name := e.After
+		if name == "" {
+			name = e.Group.GroupNickName
+		}
+		r.touch(r.group(group), name)
+		return r.saveAndUnlock(group)
+	case GroupEventAdminAdded, GroupEventAdminRemoved, GroupEventOwnerChanged:
+		role := int8(RoleAdmin)
+		switch e.Event {
+		case GroupEventAdminRemoved:
+			role = RoleMember
+		case GroupEventOwnerChanged:
+			role = RoleOwner
+		}
+		r.mu.Lock()
+		rg := r.group(group)
+		for _, m := range e.Members {
+			r.merge(rg, &Contact{UserName: m.UserName, NickName: m.NickName, HeadImg: m.HeadImg, Role: role})
+		}
+		// 原群主转让后变为普通成员
+		if e.Event == GroupEventOwnerChanged && e.ByOperator() {
+			r.merge(rg, &Contact{UserName: e.Operator.UserName, NickName: e.Operator.NickName, Role: RoleMember})
+		}
+		r.touch(rg, e.Group.GroupNickName)
+		return r.saveAndUnlock(group)
+	}
+	return nil
+}
+
+// Refresh 通过群成员接口重新获取整个群的花名册
+func (r *Roster) Refresh(bot *ChatBot, group string) error {
+	members, err := bot.GroupMembers(group)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	r.mu.Lock()
+	rg := r.group(group)
+	rg.members = make(map[string]*Contact, len(members))
+	for _, m := range members {
+		rg.members[m.UserName] = &Contact{
+			UserName:    m.UserName,
+			NickName:    m.NickName,
+			DisplayName: m.DisplayName,
+			HeadImg:     m.HeadImg,
+			Role:        m.Role,
+			UpdatedAt:   now,
+		}
+	}
+	r.touch(rg, "")
+	return r.saveAndUnlock(group)
+}
+
+// RefreshAll 刷新所有已知的群
+func (r *Roster) RefreshAll(bot *ChatBot) error {
+	for _, group := range r.Groups() {
+		if err := r.Refresh(bot, group); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Forget 删除整个群的花名册,例如机器人被移出群
+func (r *Roster) Forget(group string) error {
+	r.mu.Lock()
+	delete(r.groups, group)
+	if r.store == nil {
+		r.mu.Unlock()
+		return nil
+	}
+	r.saveMu.Lock()
+	defer r.saveMu.Unlock()
+	r.mu.Unlock()
+	return r.store.Delete(group)
+}
+
+// Member 按微信号查询群成员,group为空时查询私聊联系人
+func (r *Roster) Member(group, userName string) (Contact, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if rg, ok := r.groups[group]; ok {
+		if c, ok := rg.members[userName]; ok {
+			return *c, true
+		}
+	}
+	return Contact{}, false
+}
+
+// Lookup 在所有群中按微信号查询,返回信息最新的一条
+func (r *Roster) Lookup(userName string) (Contact, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var found *Contact
+	for _, rg := range r.groups {
+		c, ok := rg.members[userName]
+		if !ok {
+			continue
+		}
+		switch {
+		case found == nil:
+			found = c
+		case (c.NickName != "") != (found.NickName != ""):
+			// 优先使用有昵称的记录
+			if c.NickName != "" {
+				found = c
+			}
+		case c.UpdatedAt.After(found.UpdatedAt):
+			found = c
+		}
+	}
+	if found == nil {
+		return Contact{}, false
+	}
+	return *found, true
+}
+
+// FindByNickname 按微信昵称或者群昵称查询群成员,昵称可能重复所以返回列表
+func (r *Roster) FindByNickname(group, nickname string) []Contact {
+	list := make([]Contact, 0)
+	for _, c := range r.Members(group) {
+		if c.NickName == nickname || c.DisplayName == nickname {
+			list = append(list, c)
+		}
+	}
+	return list
+}
+
+// Members 群内所有已知成员,按微信号排序
+func (r *Roster) Members(group string) []Contact {
+	r.mu.RLock()
+	rg, ok := r.groups[group]
+	if !ok {
+		r.mu.RUnlock()
+		return nil
+	}
+	list := make([]Contact, 0, len(rg.members))
+	for _, c := range rg.members {
+		list = append(list, *c)
+	}
+	r.mu.RUnlock()
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].UserName < list[j].UserName
+	})
+	return list
+}
+
+// Admins 群主和管理员
+func (r *Roster) Admins(group string) []Contact {
+	list := make([]Contact, 0)
+	for _, c := range r.Members(group) {
+		if c.IsAdmin() || c.IsGroupOwner() {
+			list = append(list, c)
+		}
+	}
+	return list
+}
+
+// GroupName 群名称,只有收到过群事件或者刷新过的群才有
+func (r *Roster) GroupName(group string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if rg, ok := r.groups[group]; ok {
+		return rg.name
+	}
+	return ""
+}
+
+// Groups 所有已知的群
+func (r *Roster) Groups() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	groups := make([]string, 0, len(r.groups))
+	for g := range r.groups {
+		if g != "" {
+			groups = append(groups, g)
+		}
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+// upsert 合并成员信息,有变化时才持久化
+func (r *Roster) upsert(group string, c *Contact) error {
+	r.mu.Lock()
+	rg := r.group(group)
+	if !r.merge(rg, c) {
+		r.mu.Unlock()
+		return nil
+	}
+	r.touch(rg, "")
+	return r.saveAndUnlock(group)
+}
+
+// merge 合并成员信息,返回是否有变化,调用时需要持有锁
+// 消息中没有的字段不会覆盖已有的值
+func (r *Roster) merge(rg *rosterGroup, c *Contact) bool {
+	old, ok := rg.members[c.UserName]
+	if !ok {
+		c.UpdatedAt = time.Now()
+		rg.members[c.UserName] = c
+		return true
+	}
+	changed := false
+	if c.NickName != "" && c.NickName != old.NickName {
+		old.NickName, changed = c.NickName, true
+	}
+	if c.DisplayName != "" && c.DisplayName != old.DisplayName {
+		old.DisplayName, changed = c.DisplayName, true
+	}
+	if c.HeadImg != "" && c.HeadImg != old.HeadImg {
+		old.HeadImg, changed = c.HeadImg, true
+	}
+	if c.Role != 0 && c.Role != old.Role {
+		old.Role, changed = c.Role, true
+	}
+	if changed {
+		old.UpdatedAt = time.Now()
+	}
+	return changed
+}
+
+// group 获取群,不存在时新建,调用时需要持有锁
+func (r *Roster) group(group string) *rosterGroup {
+	rg, ok := r.groups[group]
+	if !ok {
+		rg = &rosterGroup{members: make(map[string]*Contact)}
+		r.groups[group] = rg
+	}
+	return rg
+}
+
+// touch 更新群名称和修改时间,调用时需要持有锁
+func (r *Roster) touch(rg *rosterGroup, name string) {
+	if name != "" {
+		rg.name = name
+	}
+	rg.updatedAt = time.Now()
+}
+
+// saveAndUnlock 持久化一个群,调用时需要持有锁,返回前会释放
+func (r *Roster) saveAndUnlock(group string) error {
+	if r.store == nil {
+		r.mu.Unlock()
+		return nil
+	}
+	rg := r.groups[group]
+	g := &GroupRoster{
+		Group:     group,
+		Name:      rg.name,
+		Members:   make([]*Contact, 0, len(rg.members)),
+		UpdatedAt: rg.updatedAt,
+	}
+	for _, c := range rg.members {
+		cp := *c
+		g.Members = append(g.Members, &cp)
+	}
+	// 释放mu之前获取saveMu,后修改的快照不会先于之前的快照写入
+	r.saveMu.Lock()
+	defer r.saveMu.Unlock()
+	r.mu.Unlock()
+	return r.store.Save(g)
+}
+
+// FileRosterStore 以json文件保存花名册
+type FileRosterStore struct {
+	path string
+
+	mu     sync.Mutex
+	groups map[string]*GroupRoster
+}
+
+var _ RosterStore = new(FileRosterStore)
+
+// NewFileRosterStore 新建文件存储,文件不存在时会在第一次保存时创建
+func NewFileRosterStore(path string) *FileRosterStore {
+	return &FileRosterStore{path: path}
+}
+
+func (s *FileRosterStore) Load() ([]*GroupRoster, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load()
+}
+
+// load 读取文件,调用时需要持有锁
+func (s *FileRosterStore) load() ([]*GroupRoster, error) {
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		s.groups = make(map[string]*GroupRoster)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []*GroupRoster
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	s.groups = make(map[string]*GroupRoster, len(list))
+	for _, g := range list {
+		s.groups[g.Group] = g
+	}
+	return list, nil
+}
+
+// Save 保存一个群,没有调用过Load时先读取文件,不会覆盖文件中的其他群
+func (s *FileRosterStore) Save(g *GroupRoster) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.groups == nil {
+		if _, err := s.load(); err != nil {
+			return err
+		}
+	}
+	s.groups[g.Group] = g
+	return s.flush()
+}
+
+func (s *FileRosterStore) Delete(group string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.groups == nil {
+		if _, err := s.load(); err != nil {
+			return err
+		}
+	}
+	delete(s.groups, group)
+	return s.flush()
+}
+
+// flush 写入文件,调用时需要持有锁
+func (s *FileRosterStore) flush() error {
+	list := make([]*GroupRoster, 0, len(s.groups))
+	for _, g := range s.groups {
+		list = append(list, g)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Group < list[j].Group
+	})
+	return writeJSONFile(s.path, list)
+}
@@ -0,0 +1,184 @@
+package chatbot
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func pushMessage(t *testing.T, msgType PushMsgType, data interface{}) *PushMessage {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &PushMessage{MsgType: msgType, Data: raw}
+}
+
+func TestRoster(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "roster.json")
+	r, err := NewRoster(NewFileRosterStore(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const group = "123@chatroom"
+	msgs := []*PushMessage{
+		pushMessage(t, CusMsgTypeUser, &UserMessage{
+			FromUser: group, MsgType: MsgTypeText,
+			GroupMember: "wxid_a", GroupMemberNickname: "小明", GroupMemberRole: RoleAdmin,
+		}),
+		pushMessage(t, CusMsgTypeGroupEvent, &GroupBotEvent{
+			Event:   GroupEventNewMember,
+			Group:   GroupBase{GroupUserName: group, GroupNickName: "测试群"},
+			Members: []MemberBase{{UserName: "wxid_b", NickName: "小红"}, {UserName: "wxid_c", NickName: "小明"}},
+		}),
+		pushMessage(t, CusMsgTypeGroupEvent, &GroupBotEvent{
+			Event:   GroupEventMemberQuit,
+			Group:   GroupBase{GroupUserName: group},
+			Members: []MemberBase{{UserName: "wxid_b"}},
+		}),
+	}
+	for _, m := range msgs {
+		if err := r.Do(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 重新加载后数据不丢失
+	r, err = NewRoster(NewFileRosterStore(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, ok := r.Member(group, "wxid_a"); !ok || c.NickName != "小明" || !c.IsAdmin() {
+		t.Errorf("unexpected member: %+v", c)
+	}
+	if _, ok := r.Member(group, "wxid_b"); ok {
+		t.Error("want quit member removed")
+	}
+	if list := r.FindByNickname(group, "小明"); len(list) != 2 {
+		t.Errorf("want 2 members named 小明, got %+v", list)
+	}
+	if len(r.Admins(group)) != 1 || r.GroupName(group) != "测试群" {
+		t.Error("unexpected admins or group name")
+	}
+
+	if err := r.ObserveEvent(&GroupBotEvent{Event: GroupEventKicked, Group: GroupBase{GroupUserName: group}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Groups()) != 0 {
+		t.Errorf("want group forgotten, got %v", r.Groups())
+	}
+}
+
+func TestRoster_Refresh(t *testing.T) {
+	bot := newTestBot(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"code":0,"data":{"owner":"wxid_a","memberList":[
+			{"userName":"wxid_a","nickName":"A"},{"userName":"wxid_b","nickName":"B","displayName":"bb"}]}}`))
+	})
+	r, _ := NewRoster(nil)
+	if err := r.Refresh(bot, "123@chatroom"); err != nil {
+		t.Fatal(err)
+	}
+	if c, ok := r.Lookup("wxid_a"); !ok || !c.IsGroupOwner() {
+		t.Errorf("want owner, got %+v", c)
+	}
+	if list := r.FindByNickname("123@chatroom", "bb"); len(list) != 1 {
+		t.Errorf("want member found by display name, got %+v", list)
+	}
+}
+
+func TestRoster_RoleEvents(t *testing.T) {
+	r, _ := NewRoster(nil)
+	const group = "123@chatroom"
+	events := []*GroupBotEvent{
+		{Event: GroupEventAdminAdded, Group: GroupBase{GroupUserName: group}, Members: []MemberBase{{UserName: "wxid_b"}}},
+		{Event: GroupEventOwnerChanged, Group: GroupBase{GroupUserName: group},
+			Operator: &MemberBase{UserName: "wxid_a"}, Members: []MemberBase{{UserName: "wxid_c"}}},
+		{Event: GroupEventRenamed, Group: GroupBase{GroupUserName: group}, Before: "旧群名", After: "新群名"},
+	}
+	for _, e := range events {
+		if err := r.ObserveEvent(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b, _ := r.Member(group, "wxid_b")
+	a, _ := r.Member(group, "wxid_a")
+	c, _ := r.Member(group, "wxid_c")
+	if !b.IsAdmin() || a.Role != RoleMember || !c.IsGroupOwner() {
+		t.Errorf("unexpected roles: %+v %+v %+v", a, b, c)
+	}
+	if r.GroupName(group) != "新群名" {
+		t.Errorf("want renamed group, got %s", r.GroupName(group))
+	}
+}
+
+// slowStore 第一次保存很慢,用于检查保存顺序
+type slowStore struct {
+	mu    sync.Mutex
+	first bool
+	last  map[string]*GroupRoster
+}
+
+func (s *slowStore) Load() ([]*GroupRoster, error) { return nil, nil }
+func (s *slowStore) Delete(string) error           { return nil }
+func (s *slowStore) Save(g *GroupRoster) error {
+	s.mu.Lock()
+	slow := !s.first
+	s.first = true
+	s.mu.Unlock()
+	if slow {
+		time.Sleep(50 * time.Millisecond)
+	}
+	s.mu.Lock()
+	s.last[g.Group] = g
+	s.mu.Unlock()
+	return nil
+}
+
+func TestRoster_SaveOrder(t *testing.T) {
+	store := &slowStore{last: make(map[string]*GroupRoster)}
+	r, _ := NewRoster(store)
+	const group = "123@chatroom"
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_ = r.ObserveEvent(&GroupBotEvent{
+				Event:   GroupEventNewMember,
+				Group:   GroupBase{GroupUserName: group},
+				Members: []MemberBase{{UserName: fmt.Sprintf("wxid_%d", i)}},
+			})
+		}(i)
+		// 保证第一次保存已经开始
+		if i == 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	wg.Wait()
+	// 最后写入的必须是最新的快照
+	if n := len(store.last[group].Members); n != 10 {
+		t.Errorf("want latest snapshot with 10 members persisted, got %d", n)
+	}
+}
+
+func TestFileRosterStore_SaveWithoutLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "roster.json")
+	if err := NewFileRosterStore(path).Save(&GroupRoster{Group: "a@chatroom"}); err != nil {
+		t.Fatal(err)
+	}
+	// 新的store没有调用Load,保存时不能覆盖已有的群
+	if err := NewFileRosterStore(path).Save(&GroupRoster{Group: "b@chatroom"}); err != nil {
+		t.Fatal(err)
+	}
+	list, err := NewFileRosterStore(path).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Errorf("want both groups kept, got %+v", list)
+	}
+}
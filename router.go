@@ -0,0 +1,149 @@
+package chatbot
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+type (
+	// MessageHandler 用户消息处理函数
+	MessageHandler func(ctx context.Context, msg *UserMessage) error
+	// RevokeHandler 撤回消息处理函数
+	RevokeHandler func(ctx context.Context, msg *UserMessage, revoke *RevokeInfo) error
+	// PatHandler 拍一拍处理函数
+	PatHandler func(ctx context.Context, msg *UserMessage, pat *PatInfo) error
+	// GroupEventHandler 群事件处理函数
+	GroupEventHandler func(ctx context.Context, event *GroupBotEvent) error
+)
+
+// Router 按消息类型分发的插件
+// 不需要在每个插件中解析PushMessage再自行判断类型
+//
+//	r := chatbot.NewRouter("router")
+//	r.OnMessage(chatbot.MsgTypeText, func(ctx context.Context, msg *chatbot.UserMessage) error {...})
+//	r.OnPat(func(ctx context.Context, msg *chatbot.UserMessage, pat *chatbot.PatInfo) error {...})
+//	bot.Use(r)
+type Router struct {
+	name string
+
+	mu       sync.RWMutex
+	messages map[int][]MessageHandler
+	revokes  []RevokeHandler
+	pats     []PatHandler
+	events   map[GroupEvent][]GroupEventHandler
+}
+
+var _ Plugin = new(Router)
+
+// NewRouter 新建Router
+func NewRouter(name string) *Router {
+	return &Router{
+		name:     name,
+		messages: make(map[int][]MessageHandler),
+		events:   make(map[GroupEvent][]GroupEventHandler),
+	}
+}
+
+func (r *Router) Name() string {
+	return r.name
+}
+
+// OnMessage 处理指定类型的用户消息,msgType为MsgType*常量
+func (r *Router) OnMessage(msgType int, h MessageHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages[msgType] = append(r.messages[msgType], h)
+}
+
+// OnSystem 处理系统提示消息,包括MsgTypeSys和未被OnRevoke、OnPat处理的MsgTypeSysNotice
+func (r *Router) OnSystem(h MessageHandler) {
+	r.OnMessage(MsgTypeSys, h)
+	r.OnMessage(MsgTypeSysNotice, h)
+}
+
+// OnRevoke 处理撤回消息
+func (r *Router) OnRevoke(h RevokeHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.revokes = append(r.revokes, h)
+}
+
+// OnPat 处理拍一拍
+func (r *Router) OnPat(h PatHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pats = append(r.pats, h)
+}
+
+// OnGroupEvent 处理群事件
+func (r *Router) OnGroupEvent(event GroupEvent, h GroupEventHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events[event] = append(r.events[event], h)
+}
+
+func (r *Router) Do(msg *PushMessage) error {
+	switch msg.MsgType {
+	case CusMsgTypeUser:
+		m := &UserMessage{}
+		if err := json.Unmarshal(msg.Data, m); err != nil {
+			return err
+		}
+		return r.handleMessage(msg.Context(), m)
+	case CusMsgTypeGroupEvent:
+		e := &GroupBotEvent{}
+		if err := json.Unmarshal(msg.Data, e); err != nil {
+			return err
+		}
+		r.mu.RLock()
+		handlers := r.events[e.Event]
+		r.mu.RUnlock()
+		for _, h := range handlers {
+			if err := h(msg.Context(), e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Router) handleMessage(ctx context.Context, msg *UserMessage) error {
+	r.mu.RLock()
+	handlers := r.messages[msg.MsgType]
+	revokes, pats := r.revokes, r.pats
+	r.mu.RUnlock()
+
+	if msg.MsgType == MsgTypeSysNotice && (len(revokes) > 0 || len(pats) > 0) {
+		content := msg.Content
+		if IsGroupMessage(msg.FromUser) && msg.GroupContent != "" {
+			content = msg.GroupContent
+		}
+		// 不是<sysmsg>格式的系统通知交给OnSystem处理
+		if sys, err := ParseSysMessage(content); err == nil {
+			switch {
+			case sys.Revoke != nil && len(revokes) > 0:
+				for _, h := range revokes {
+					if err := h(ctx, msg, sys.Revoke); err != nil {
+						return err
+					}
+				}
+				return nil
+			case sys.Pat != nil && len(pats) > 0:
+				for _, h := range pats {
+					if err := h(ctx, msg, sys.Pat); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+		}
+	}
+
+	for _, h := range handlers {
+		if err := h(ctx, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
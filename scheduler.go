@@ -0,0 +1,498 @@
+package chatbot
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrJobNotFound 定时任务不存在
+var ErrJobNotFound = errors.New("job not found")
+
+// JobHandler 定时任务的处理函数
+// 函数无法持久化,需要在NewScheduler之后通过Scheduler.Handle按名称注册
+type JobHandler func(ctx context.Context, bot *ChatBot, job *Job) error
+
+// Job 定时任务
+// At不为零时为一次性任务,否则按Cron周期执行
+// Handler不为空时调用注册的处理函数,否则向ToUser发送Content
+type Job struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`      // 任务名称,用于展示
+	ToUser    string    `json:"toUser"`    // 接收人微信号或者群号
+	Content   string    `json:"content"`   // 发送的文本
+	AtList    []string  `json:"atList"`    // 群内@的人微信号
+	Handler   string    `json:"handler"`   // 处理函数名称
+	Args      string    `json:"args"`      // 处理函数的参数
+	At        time.Time `json:"at"`        // 一次性任务的执行时间
+	Cron      string    `json:"cron"`      // 周期任务的cron表达式
+	TimeZone  string    `json:"timeZone"`  // cron使用的时区,例如Asia/Shanghai,默认本地时区
+	CreatedBy string    `json:"createdBy"` // 创建人
+	CreatedAt time.Time `json:"createdAt"`
+	NextRun   time.Time `json:"nextRun"`
+	LastRun   time.Time `json:"lastRun"`
+	LastError string    `json:"lastError"`
+
+	schedule *CronSchedule
+}
+
+// IsRecurring 是否为周期任务
+func (j *Job) IsRecurring() bool {
+	return j.At.IsZero()
+}
+
+// JobStore 定时任务的持久化接口
+type JobStore interface {
+	Load() ([]*Job, error)
+	Save(job *Job) error
+	Delete(id string) error
+}
+
+// Scheduler 定时任务
+// 一次性任务和cron周期任务,保存在JobStore中,重启后继续执行
+// 重启期间错过的一次性任务会在启动后立即执行,错过的周期任务会跳过
+//
+//	s, _ := chatbot.NewScheduler(bot, chatbot.NewFileJobStore("jobs.json"))
+//	s.Every("CRON_TZ=Asia/Shanghai 30 9 * * 1-5", "123@chatroom", "站会时间到了")
+//	s.Start()
+type Scheduler struct {
+	bot   *ChatBot
+	store JobStore
+	// saveMu 保证按修改的顺序写入store,需要在持有mu时获取
+	saveMu sync.Mutex
+
+	mu       sync.Mutex
+	jobs     map[string]*Job
+	handlers map[string]JobHandler
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// NewScheduler 新建定时任务,store为nil时任务只保存在内存中
+func NewScheduler(bot *ChatBot, store JobStore) (*Scheduler, error) {
+	s := &Scheduler{
+		bot:      bot,
+		store:    store,
+		jobs:     make(map[string]*Job),
+		handlers: make(map[string]JobHandler),
+	}
+	if store == nil {
+		return s, nil
+	}
+	jobs, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, j := range jobs {
+		if err := j.prepare(); err != nil {
+			log.Printf("加载定时任务%s失败:%s \n", j.ID, err)
+			continue
+		}
+		if j.IsRecurring() && j.NextRun.Before(now) {
+			j.NextRun = j.schedule.Next(now)
+		}
+		s.jobs[j.ID] = j
+	}
+	return s, nil
+}
+
+// prepare 解析cron表达式并计算下一次执行时间
+func (j *Job) prepare() error {
+	if !j.IsRecurring() {
+		if j.NextRun.IsZero() {
+			j.NextRun = j.At
+		}
+		return nil
+	}
+	var loc *time.Location
+	if j.TimeZone != "" {
+		l, err := time.LoadLocation(j.TimeZone)
+		if err != nil {
+			return fmt.Errorf("未知的时区%s:%w", j.TimeZone, err)
+		}
+		loc = l
+	}
+	schedule, err := ParseCron(j.Cron, loc)
+	if err != nil {
+		return err
+	}
+	j.schedule = schedule
+	if j.NextRun.IsZero() {
+		j.NextRun = schedule.Next(time.Now())
+	}
+	if j.NextRun.IsZero() {
+		return fmt.Errorf("cron表达式没有可执行的时间:%s", j.Cron)
+	}
+	return nil
+}
+
+// Handle 注册处理函数,Job.Handler为name的任务会调用该函数
+func (s *Scheduler) Handle(name string, h JobHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[name] = h
+}
+
+// Add 添加任务,ID为空时自动生成
+func (s *Scheduler) Add(job *Job) (*Job, error) {
+	if job.At.IsZero() && job.Cron == "" {
+		return nil, errors.New("job需要设置At或者Cron")
+	}
+	if job.Handler == "" && (job.ToUser == "" || job.Content == "") {
+		return nil, errors.New("job需要设置Handler或者ToUser和Content")
+	}
+	j := *job
+	j.NextRun = time.Time{}
+	if err := j.prepare(); err != nil {
+		return nil, err
+	}
+	if j.ID == "" {
+		j.ID = newJobID()
+	}
+	if j.CreatedAt.IsZero() {
+		j.CreatedAt = time.Now()
+	}
+	cp := j
+	s.mu.Lock()
+	s.jobs[j.ID] = &j
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	s.mu.Unlock()
+	// 返回副本,保存在jobs中的任务会被执行循环修改
+	if err := s.save(&cp); err != nil {
+		return nil, err
+	}
+	return &cp, nil
+}
+
+// At 在指定时间发送一次文本消息
+func (s *Scheduler) At(at time.Time, toUser, content string) (*Job, error) {
+	return s.Add(&Job{At: at, ToUser: toUser, Content: content})
+}
+
+// Every 按cron表达式周期发送文本消息,时区可以用CRON_TZ=前缀指定
+func (s *Scheduler) Every(cron, toUser, content string) (*Job, error) {
+	return s.Add(&Job{Cron: cron, ToUser: toUser, Content: content})
+}
+
+// Cancel 取消任务
+func (s *Scheduler) Cancel(id string) error {
+	s.mu.Lock()
+	_, ok := s.jobs[id]
+	delete(s.jobs, id)
+	if !ok || s.store == nil {
+		s.mu.Unlock()
+		if !ok {
+			return ErrJobNotFound
+		}
+		return nil
+	}
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	s.mu.Unlock()
+	return s.store.Delete(id)
+}
+
+// Job 获取任务
+func (s *Scheduler) Job(id string) (Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *j, true
+}
+
+// Jobs 所有任务,按下一次执行时间排序
+func (s *Scheduler) Jobs() []Job {
+	s.mu.Lock()
+	list := make([]Job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		list = append(list, *j)
+	}
+	s.mu.Unlock()
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].NextRun.Before(list[j].NextRun)
+	})
+	return list
+}
+
+// Start 开始在后台执行任务
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(s.bot.context())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	go s.loop(ctx, s.done)
+}
+
+// Stop 停止执行任务,等待正在执行的任务结束
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.cancel, s.done = nil, nil
+	s.mu.Unlock()
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+func (s *Scheduler) loop(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	s.runDue(ctx, time.Now())
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.runDue(ctx, now)
+		}
+	}
+}
+
+// runDue 执行所有到期的任务
+func (s *Scheduler) runDue(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	due := make([]*Job, 0)
+	for _, j := range s.jobs {
+		if !j.NextRun.After(now) {
+			due = append(due, j)
+		}
+	}
+	s.mu.Unlock()
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].NextRun.Before(due[j].NextRun)
+	})
+	for _, j := range due {
+		if ctx.Err() != nil {
+			return
+		}
+		s.run(ctx, j, now)
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, j *Job, now time.Time) {
+	s.mu.Lock()
+	snapshot := *j
+	h := s.handlers[j.Handler]
+	s.mu.Unlock()
+
+	var err error
+	bot := s.bot.WithContext(ctx)
+	switch {
+	case snapshot.Handler != "" && h == nil:
+		err = fmt.Errorf("处理函数%s未注册", snapshot.Handler)
+	case snapshot.Handler != "":
+		err = h(ctx, bot, &snapshot)
+	default:
+		err = bot.SendText(snapshot.ToUser, snapshot.Content, snapshot.AtList)
+	}
+	if err != nil {
+		log.Printf("定时任务%s执行失败:%s \n", snapshot.ID, err)
+	}
+
+	s.mu.Lock()
+	// 执行期间任务可能被取消
+	if _, ok := s.jobs[j.ID]; !ok {
+		s.mu.Unlock()
+		return
+	}
+	j.LastRun = now
+	j.LastError = ""
+	if err != nil {
+		j.LastError = err.Error()
+	}
+	// 释放mu之前获取saveMu,避免和Cancel交错导致已取消的任务被重新写入
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	if !j.IsRecurring() {
+		delete(s.jobs, j.ID)
+		s.mu.Unlock()
+		if s.store != nil {
+			if err := s.store.Delete(j.ID); err != nil {
+				log.Printf("删除定时任务%s失败:%s \n", j.ID, err)
+			}
+		}
+		return
+	}
+	j.NextRun = j.schedule.Next(now)
+	cp := *j
+	s.mu.Unlock()
+	if err := s.save(&cp); err != nil {
+		log.Printf("保存定时任务%s失败:%s \n", j.ID, err)
+	}
+}
+
+func (s *Scheduler) save(j *Job) error {
+	if s.store == nil {
+		return nil
+	}
+	return s.store.Save(j)
+}
+
+// RegisterCommands 在Router上注册管理定时任务的聊天命令
+// /jobs 查看任务,/cancel <id> 取消任务
+// 群内只有群主和管理员可以使用,并且只能管理发给本群的任务,私聊只有admins中的用户可以使用
+func (s *Scheduler) RegisterCommands(r *Router, admins ...string) {
+	r.OnMessage(MsgTypeText, func(ctx context.Context, msg *UserMessage) error {
+		group := ""
+		if IsGroupMessage(msg.FromUser) {
+			if !msg.IsAdmin() && !msg.IsGroupOwner() {
+				return nil
+			}
+			group = msg.FromUser
+		} else if !containsString(admins, msg.FromUser) {
+			return nil
+		}
+		_, text := msg.Mentions(nil)
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			return nil
+		}
+		bot := s.bot.WithContext(ctx)
+		switch fields[0] {
+		case "/jobs":
+			return bot.SendText(msg.FromUser, s.describeJobs(group), nil)
+		case "/cancel":
+			if len(fields) < 2 {
+				return bot.SendText(msg.FromUser, "用法:/cancel <任务id>", nil)
+			}
+			id := fields[1]
+			if j, ok := s.Job(id); !ok || (group != "" && j.ToUser != group) {
+				return bot.SendText(msg.FromUser, "任务不存在:"+id, nil)
+			}
+			if err := s.Cancel(id); err != nil {
+				return err
+			}
+			return bot.SendText(msg.FromUser, "已取消任务:"+id, nil)
+		}
+		return nil
+	})
+}
+
+// describeJobs 任务列表的文本,group不为空时只包含发给该群的任务
+func (s *Scheduler) describeJobs(group string) string {
+	var sb strings.Builder
+	for _, j := range s.Jobs() {
+		if group != "" && j.ToUser != group {
+			continue
+		}
+		name := j.Name
+		if name == "" {
+			name = j.Content
+			if j.Handler != "" {
+				name = j.Handler
+			}
+		}
+		if r := []rune(name); len(r) > 20 {
+			name = string(r[:20]) + "…"
+		}
+		when := "cron " + j.Cron
+		if !j.IsRecurring() {
+			when = "at"
+		}
+		fmt.Fprintf(&sb, "%s %s 下次:%s %s\n", j.ID, when, j.NextRun.Format("2006-01-02 15:04 MST"), name)
+	}
+	if sb.Len() == 0 {
+		return "没有定时任务"
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func newJobID() string {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// FileJobStore 以json文件保存定时任务
+type FileJobStore struct {
+	path string
+
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+var _ JobStore = new(FileJobStore)
+
+// NewFileJobStore 新建文件存储,文件不存在时会在第一次保存时创建
+func NewFileJobStore(path string) *FileJobStore {
+	return &FileJobStore{path: path, jobs: make(map[string]*Job)}
+}
+
+func (s *FileJobStore) Load() ([]*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs = make(map[string]*Job)
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []*Job
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	for _, j := range list {
+		cp := *j
+		s.jobs[j.ID] = &cp
+	}
+	return list, nil
+}
+
+func (s *FileJobStore) Save(job *Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp := *job
+	s.jobs[job.ID] = &cp
+	return s.flush()
+}
+
+func (s *FileJobStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.jobs, id)
+	return s.flush()
+}
+
+// flush 写入文件,调用时需要持有锁
+func (s *FileJobStore) flush() error {
+	list := make([]*Job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		list = append(list, j)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+	return writeJSONFile(s.path, list)
+}
@@ -0,0 +1,185 @@
+package chatbot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScheduler(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		sent []*SendTextRequest
+	)
+	bot := newTestBot(t, func(w http.ResponseWriter, r *http.Request) {
+		req := &SendTextRequest{}
+		_ = json.NewDecoder(r.Body).Decode(req)
+		mu.Lock()
+		sent = append(sent, req)
+		mu.Unlock()
+		_, _ = w.Write([]byte(`{"code":0,"data":{}}`))
+	})
+	path := filepath.Join(t.TempDir(), "jobs.json")
+	s, err := NewScheduler(bot, NewFileJobStore(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	once, err := s.At(now.Add(time.Hour), "123@chatroom", "开会")
+	if err != nil {
+		t.Fatal(err)
+	}
+	daily, err := s.Add(&Job{Cron: "0 9 * * *", TimeZone: "UTC", Handler: "report"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Every("bad", "123@chatroom", "x"); err == nil {
+		t.Error("want cron error")
+	}
+
+	// 重启后任务不丢失
+	s, err = NewScheduler(bot, NewFileJobStore(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Jobs()) != 2 {
+		t.Fatalf("want 2 jobs loaded, got %d", len(s.Jobs()))
+	}
+	var called string
+	s.Handle("report", func(ctx context.Context, bot *ChatBot, job *Job) error {
+		called = job.ID
+		return nil
+	})
+
+	s.runDue(context.Background(), now.Add(2*time.Hour))
+	if _, ok := s.Job(once.ID); ok {
+		t.Error("want one-shot job removed after run")
+	}
+	if len(sent) != 1 || sent[0].Content != "开会" {
+		t.Errorf("unexpected sent: %+v", sent)
+	}
+
+	next := time.Date(now.Year(), now.Month(), now.Day(), 9, 0, 0, 0, time.UTC).AddDate(0, 0, 2)
+	s.runDue(context.Background(), next)
+	j, _ := s.Job(daily.ID)
+	if called != daily.ID || !j.NextRun.Equal(next.AddDate(0, 0, 1)) {
+		t.Errorf("unexpected recurring job: called %s, next %s", called, j.NextRun)
+	}
+
+	if err := s.Cancel(daily.ID); err != nil || len(s.Jobs()) != 0 {
+		t.Errorf("want job cancelled, got %v", err)
+	}
+	if err := s.Cancel(daily.ID); err != ErrJobNotFound {
+		t.Errorf("want ErrJobNotFound, got %v", err)
+	}
+}
+
+func TestScheduler_Commands(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		replies []string
+	)
+	bot := newTestBot(t, func(w http.ResponseWriter, r *http.Request) {
+		req := &SendTextRequest{}
+		_ = json.NewDecoder(r.Body).Decode(req)
+		mu.Lock()
+		replies = append(replies, req.Content)
+		mu.Unlock()
+		_, _ = w.Write([]byte(`{"code":0,"data":{}}`))
+	})
+	s, _ := NewScheduler(bot, nil)
+	mine, _ := s.Every("0 9 * * 1-5", "123@chatroom", "站会时间到了")
+	other, _ := s.Every("0 9 * * *", "456@chatroom", "日报")
+
+	r := NewRouter("scheduler")
+	s.RegisterCommands(r, "wxid_admin")
+	send := func(from, member string, role int8, content string) {
+		t.Helper()
+		msg := &UserMessage{FromUser: from, MsgType: MsgTypeText, Content: content, GroupContent: content,
+			GroupMember: member, GroupMemberRole: role}
+		if err := r.Do(pushMessage(t, CusMsgTypeUser, msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	send("123@chatroom", "wxid_a", RoleMember, "/jobs")
+	if len(replies) != 0 {
+		t.Fatal("want members ignored")
+	}
+	send("123@chatroom", "wxid_b", RoleAdmin, "/jobs")
+	if len(replies) != 1 || !strings.Contains(replies[0], mine.ID) || strings.Contains(replies[0], other.ID) {
+		t.Fatalf("want only jobs of this group, got %q", replies)
+	}
+	send("123@chatroom", "wxid_b", RoleAdmin, "/cancel "+other.ID)
+	if _, ok := s.Job(other.ID); !ok {
+		t.Error("want jobs of other groups protected")
+	}
+	send("wxid_admin", "", 0, "/cancel "+other.ID)
+	if _, ok := s.Job(other.ID); ok {
+		t.Error("want job cancelled by admin")
+	}
+}
+
+// blockingJobStore 执行后的保存会等待一段时间,用于检查和Cancel的顺序
+type blockingJobStore struct {
+	mu     sync.Mutex
+	jobs   map[string]bool
+	saving chan struct{}
+}
+
+func (s *blockingJobStore) Load() ([]*Job, error) { return nil, nil }
+
+func (s *blockingJobStore) Save(j *Job) error {
+	if !j.LastRun.IsZero() {
+		close(s.saving)
+		time.Sleep(50 * time.Millisecond)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[j.ID] = true
+	return nil
+}
+
+func (s *blockingJobStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.jobs, id)
+	return nil
+}
+
+func TestScheduler_CancelDuringSave(t *testing.T) {
+	store := &blockingJobStore{jobs: make(map[string]bool), saving: make(chan struct{})}
+	s, err := NewScheduler(&ChatBot{}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Handle("noop", func(ctx context.Context, bot *ChatBot, job *Job) error { return nil })
+	job, err := s.Add(&Job{Cron: "* * * * *", Handler: "noop"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 返回的是副本,修改后不影响调度中的任务
+	job.NextRun = time.Time{}
+	if j, _ := s.Job(job.ID); j.NextRun.IsZero() {
+		t.Error("want Add to return a copy")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.runDue(context.Background(), time.Now().Add(2*time.Minute))
+	}()
+	<-store.saving
+	if err := s.Cancel(job.ID); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	if store.jobs[job.ID] {
+		t.Error("want cancelled job not written back to the store")
+	}
+}
@@ -0,0 +1,82 @@
+// Package silk 处理微信语音使用的SILK v3文件格式
+//
+// 包内只实现了SILK v3的文件封装(帧的读写)以及WAV/PCM的读写和重采样,
+// 不包含SILK音频本身的编解码。WAV转silk需要自行实现chatbot.VoiceEncoder,
+// 例如包装SILK SDK,再通过chatbot.WithVoiceEncoder接入
+package silk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// DefaultSampleRate 微信语音使用的采样率
+const DefaultSampleRate = 24000
+
+const (
+	header = "#!SILK_V3"
+	// 微信的silk文件在标准头前面多了一个字节
+	tencentPrefix = 0x02
+	// 部分文件使用0xFFFF作为结束标记
+	endMarker = 0xFFFF
+)
+
+var (
+	// ErrNotSilk 不是SILK v3格式
+	ErrNotSilk = errors.New("data is not silk v3")
+	// ErrNotWAV 不是支持的WAV格式
+	ErrNotWAV = errors.New("data is not 16-bit pcm wav")
+)
+
+// IsSilk 是否为SILK v3文件,兼容微信在头部多出的一个字节
+func IsSilk(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(header)) ||
+		(len(data) > 0 && data[0] == tencentPrefix && bytes.HasPrefix(data[1:], []byte(header)))
+}
+
+// ReadFrames 读取SILK v3文件中的所有帧
+// 每帧为2字节小端长度加上帧数据
+func ReadFrames(data []byte) ([][]byte, error) {
+	if !IsSilk(data) {
+		return nil, ErrNotSilk
+	}
+	if data[0] == tencentPrefix {
+		data = data[1:]
+	}
+	r := bytes.NewReader(data[len(header):])
+	var frames [][]byte
+	for {
+		var n uint16
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			if err == io.EOF {
+				return frames, nil
+			}
+			return nil, err
+		}
+		if n == endMarker {
+			return frames, nil
+		}
+		frame := make([]byte, n)
+		if _, err := io.ReadFull(r, frame); err != nil {
+			return nil, err
+		}
+		frames = append(frames, frame)
+	}
+}
+
+// WriteFrames 把帧写成SILK v3文件
+// @tencent 是否写成微信使用的格式,发送给微信时需要为true
+func WriteFrames(frames [][]byte, tencent bool) []byte {
+	var buf bytes.Buffer
+	if tencent {
+		buf.WriteByte(tencentPrefix)
+	}
+	buf.WriteString(header)
+	for _, f := range frames {
+		_ = binary.Write(&buf, binary.LittleEndian, uint16(len(f)))
+		buf.Write(f)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,47 @@
+package silk
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFrames(t *testing.T) {
+	frames := [][]byte{{1, 2, 3}, {4, 5}}
+	data := WriteFrames(frames, true)
+	if !IsSilk(data) || data[0] != tencentPrefix {
+		t.Fatal("want tencent silk header")
+	}
+	got, err := ReadFrames(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || !bytes.Equal(got[0], frames[0]) || !bytes.Equal(got[1], frames[1]) {
+		t.Errorf("unexpected frames %v", got)
+	}
+	if _, err := ReadFrames([]byte("RIFF")); err != ErrNotSilk {
+		t.Errorf("want ErrNotSilk, got %v", err)
+	}
+}
+
+func TestWAV(t *testing.T) {
+	pcm := []int16{0, 1000, -1000, 32767, -32768}
+	wav := EncodeWAV(pcm, 16000)
+	if !IsWAV(wav) {
+		t.Fatal("want wav header")
+	}
+	got, rate, err := DecodeWAV(wav)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rate != 16000 || len(got) != len(pcm) {
+		t.Fatalf("unexpected rate %d or length %d", rate, len(got))
+	}
+	for i := range pcm {
+		if got[i] != pcm[i] {
+			t.Errorf("sample %d: want %d, got %d", i, pcm[i], got[i])
+		}
+	}
+	if n := len(Resample(pcm, 16000, 24000)); n != 7 {
+		t.Errorf("want 7 samples after resample, got %d", n)
+	}
+}
@@ -0,0 +1,105 @@
+package silk
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// IsWAV 是否为WAV文件
+func IsWAV(data []byte) bool {
+	return len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WAVE"
+}
+
+// DecodeWAV 读取16位PCM编码的WAV,多声道会混为单声道
+func DecodeWAV(data []byte) ([]int16, int, error) {
+	if !IsWAV(data) {
+		return nil, 0, ErrNotWAV
+	}
+	var (
+		channels   uint16
+		sampleRate uint32
+		bits       uint16
+		format     uint16
+		pcm        []byte
+	)
+	for offset := 12; offset+8 <= len(data); {
+		id := string(data[offset : offset+4])
+		size := int(binary.LittleEndian.Uint32(data[offset+4 : offset+8]))
+		body := offset + 8
+		end := body + size
+		if end > len(data) {
+			end = len(data)
+		}
+		switch id {
+		case "fmt ":
+			if end-body < 16 {
+				return nil, 0, ErrNotWAV
+			}
+			format = binary.LittleEndian.Uint16(data[body:])
+			channels = binary.LittleEndian.Uint16(data[body+2:])
+			sampleRate = binary.LittleEndian.Uint32(data[body+4:])
+			bits = binary.LittleEndian.Uint16(data[body+14:])
+		case "data":
+			pcm = data[body:end]
+		}
+		// chunk按2字节对齐
+		offset = end + size%2
+	}
+	if format != 1 || bits != 16 || channels == 0 || sampleRate == 0 || pcm == nil {
+		return nil, 0, ErrNotWAV
+	}
+
+	frames := len(pcm) / 2 / int(channels)
+	samples := make([]int16, frames)
+	for i := 0; i < frames; i++ {
+		var sum int
+		for c := 0; c < int(channels); c++ {
+			p := (i*int(channels) + c) * 2
+			sum += int(int16(binary.LittleEndian.Uint16(pcm[p:])))
+		}
+		samples[i] = int16(sum / int(channels))
+	}
+	return samples, int(sampleRate), nil
+}
+
+// EncodeWAV 把单声道16位PCM写成WAV
+func EncodeWAV(pcm []int16, sampleRate int) []byte {
+	dataSize := len(pcm) * 2
+	var buf bytes.Buffer
+	buf.Grow(44 + dataSize)
+	buf.WriteString("RIFF")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(36+dataSize))
+	buf.WriteString("WAVEfmt ")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(16))
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(1)) // PCM
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(1)) // 单声道
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(sampleRate))
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(sampleRate*2))
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(2))
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(16))
+	buf.WriteString("data")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(dataSize))
+	_ = binary.Write(&buf, binary.LittleEndian, pcm)
+	return buf.Bytes()
+}
+
+// Resample 线性插值重采样
+func Resample(pcm []int16, from, to int) []int16 {
+	if from == to || from <= 0 || to <= 0 || len(pcm) == 0 {
+		return pcm
+	}
+	n := int(int64(len(pcm)) * int64(to) / int64(from))
+	out := make([]int16, n)
+	ratio := float64(from) / float64(to)
+	for i := range out {
+		pos := float64(i) * ratio
+		j := int(pos)
+		if j >= len(pcm)-1 {
+			out[i] = pcm[len(pcm)-1]
+			continue
+		}
+		frac := pos - float64(j)
+		out[i] = int16(float64(pcm[j])*(1-frac) + float64(pcm[j+1])*frac)
+	}
+	return out
+}
@@ -0,0 +1,190 @@
+package chatbot
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Sticker 表情库中的表情
+type Sticker struct {
+	Md5     string    `json:"md5"`
+	Len     int64     `json:"len"`
+	Name    string    `json:"name"`
+	Tags    []string  `json:"tags"`
+	CdnUrl  string    `json:"cdnUrl"`
+	AddedAt time.Time `json:"addedAt"`
+}
+
+func (s *Sticker) hasTag(tag string) bool {
+	for _, t := range s.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// StickerLibrary 本地表情库
+// 保存收到的表情的md5和len,之后可以按名称或者标签发送
+// 数据以json格式保存在文件中,path为空时只保存在内存里
+type StickerLibrary struct {
+	path string
+
+	mu       sync.RWMutex
+	stickers map[string]*Sticker
+}
+
+// NewStickerLibrary 新建表情库,文件存在时会加载已有的表情
+func NewStickerLibrary(path string) (*StickerLibrary, error) {
+	l := &StickerLibrary{path: path, stickers: make(map[string]*Sticker)}
+	if path == "" {
+		return l, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []*Sticker
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	for _, s := range list {
+		l.stickers[s.Md5] = s
+	}
+	return l, nil
+}
+
+// SaveXML 保存收到的表情消息,md5相同时更新名称和标签
+func (l *StickerLibrary) SaveXML(xml, name string, tags ...string) (*Sticker, error) {
+	info, err := ParseEmojiXML(xml)
+	if err != nil {
+		return nil, err
+	}
+	if info.Md5 == "" {
+		return nil, ErrEmptyEmojiMd5
+	}
+	s := &Sticker{
+		Md5:    info.Md5,
+		Len:    info.Len,
+		Name:   name,
+		Tags:   tags,
+		CdnUrl: info.CdnUrl,
+	}
+	return s, l.Save(s)
+}
+
+// Save 保存表情,md5相同时覆盖
+func (l *StickerLibrary) Save(s *Sticker) error {
+	if s.Md5 == "" || s.Len <= 0 {
+		return errors.New("sticker md5 or len is empty")
+	}
+	if s.AddedAt.IsZero() {
+		s.AddedAt = time.Now()
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	old, ok := l.stickers[s.Md5]
+	l.stickers[s.Md5] = s
+	if err := l.flush(); err != nil {
+		// 写入失败时回滚,保证内存和文件一致
+		if ok {
+			l.stickers[s.Md5] = old
+		} else {
+			delete(l.stickers, s.Md5)
+		}
+		return err
+	}
+	return nil
+}
+
+// Remove 删除表情
+func (l *StickerLibrary) Remove(md5 string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	old, ok := l.stickers[md5]
+	if !ok {
+		return nil
+	}
+	delete(l.stickers, md5)
+	if err := l.flush(); err != nil {
+		l.stickers[md5] = old
+		return err
+	}
+	return nil
+}
+
+// Get 按名称获取表情
+func (l *StickerLibrary) Get(name string) (*Sticker, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for _, s := range l.stickers {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+// FindByTag 获取带有标签的所有表情
+func (l *StickerLibrary) FindByTag(tag string) []*Sticker {
+	var list []*Sticker
+	for _, s := range l.List() {
+		if s.hasTag(tag) {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// Random 随机获取一个带有标签的表情
+func (l *StickerLibrary) Random(tag string) (*Sticker, bool) {
+	list := l.FindByTag(tag)
+	if len(list) == 0 {
+		return nil, false
+	}
+	return list[rand.Intn(len(list))], true
+}
+
+// List 所有表情,按添加时间排序
+func (l *StickerLibrary) List() []*Sticker {
+	l.mu.RLock()
+	list := make([]*Sticker, 0, len(l.stickers))
+	for _, s := range l.stickers {
+		list = append(list, s)
+	}
+	l.mu.RUnlock()
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].AddedAt.Before(list[j].AddedAt)
+	})
+	return list
+}
+
+// flush 写入文件,调用时需要持有锁
+func (l *StickerLibrary) flush() error {
+	if l.path == "" {
+		return nil
+	}
+	list := make([]*Sticker, 0, len(l.stickers))
+	for _, s := range l.stickers {
+		list = append(list, s)
+	}
+	return writeJSONFile(l.path, list)
+}
+
+// SendSticker 发送表情库中的表情
+func (bot *ChatBot) SendSticker(toUser string, s *Sticker) error {
+	if s == nil {
+		return errors.New("sticker is nil")
+	}
+	return bot.SendEmoji(toUser, s.Md5, strconv.FormatInt(s.Len, 10))
+}
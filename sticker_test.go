@@ -0,0 +1,83 @@
+package chatbot
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+const testEmojiXML = `<msg><emoji fromusername="wxid_a" tousername="123@chatroom" type="2" md5="ba6fbc6b6aa58d4ea3c5d4a1d5fb3cbd" len="24563" productid="" cdnurl="http://emoji.qpic.cn/wx_emoji/abc/" width="240" height="240" /></msg>`
+
+func TestParseEmojiXML(t *testing.T) {
+	info, err := ParseEmojiXML(testEmojiXML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Md5 != "ba6fbc6b6aa58d4ea3c5d4a1d5fb3cbd" || info.Len != 24563 || info.CdnUrl != "http://emoji.qpic.cn/wx_emoji/abc/" {
+		t.Errorf("unexpected emoji: %+v", info)
+	}
+
+	// 缺少emoji元素时不能panic
+	if _, err := (&ChatBot{}).DownloadEmoji(`<msg><img /></msg>`); err == nil {
+		t.Error("want error for xml without emoji")
+	}
+	if _, _, err := (&ChatBot{}).ParseEmojiXML(`<msg><emoji /></msg>`); err != ErrEmptyEmojiMd5 {
+		t.Errorf("want ErrEmptyEmojiMd5, got %v", err)
+	}
+	// 没有md5的表情仍然可以通过cdnurl下载
+	if u, err := (&ChatBot{}).DownloadEmoji(`<msg><emoji cdnurl="http://emoji.qpic.cn/a/" /></msg>`); err != nil || u != "http://emoji.qpic.cn/a/" {
+		t.Errorf("want cdnurl without md5, got %q %v", u, err)
+	}
+}
+
+func TestStickerLibrary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stickers.json")
+	l, err := NewStickerLibrary(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.SaveXML(testEmojiXML, "狗头", "搞笑"); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err = NewStickerLibrary(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, ok := l.Get("狗头")
+	if !ok || s.Len != 24563 {
+		t.Fatalf("want sticker loaded from file, got %+v", s)
+	}
+	if r, ok := l.Random("搞笑"); !ok || r.Md5 != s.Md5 {
+		t.Error("want sticker found by tag")
+	}
+	if err := l.Remove(s.Md5); err != nil || len(l.List()) != 0 {
+		t.Error("want sticker removed")
+	}
+}
+
+func TestStickerLibrary_Rollback(t *testing.T) {
+	l, err := NewStickerLibrary(filepath.Join(t.TempDir(), "stickers.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := l.SaveXML(testEmojiXML, "狗头")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 目录不存在,写入文件失败
+	l.path = filepath.Join(t.TempDir(), "missing", "stickers.json")
+	if err := l.Save(&Sticker{Md5: s.Md5, Len: s.Len, Name: "新名字"}); err == nil {
+		t.Fatal("want flush error")
+	}
+	if err := l.Save(&Sticker{Md5: "other", Len: 1, Name: "其他"}); err == nil {
+		t.Fatal("want flush error")
+	}
+	if err := l.Remove(s.Md5); err == nil {
+		t.Fatal("want flush error")
+	}
+	list := l.List()
+	if len(list) != 1 || list[0].Name != "狗头" {
+		t.Errorf("want changes rolled back, got %+v", list)
+	}
+}
@@ -0,0 +1,76 @@
+package chatbot
+
+import "strconv"
+
+// MsgTypeSysNotice消息中sysmsg的type属性
+const (
+	SysMsgTypeRevoke = "revokemsg" // 撤回消息
+	SysMsgTypePat    = "pat"       // 拍一拍
+)
+
+// SysMessage 解析后的sysmsg
+// 根据Type只会有一个对应的子类型字段不为nil
+type SysMessage struct {
+	Type string // sysmsg的type属性
+	Raw  string // 原始xml
+
+	Revoke *RevokeInfo
+	Pat    *PatInfo
+}
+
+// RevokeInfo 撤回消息
+type RevokeInfo struct {
+	Session    string // 撤回消息所在会话,群消息时为群号
+	MsgID      int64  // 被撤回消息的客户端id
+	NewMsgID   int64  // 被撤回消息的服务端id,对应UserMessage.NewMsgID
+	ReplaceMsg string // 撤回提示,例如"xx" 撤回了一条消息
+}
+
+// PatInfo 拍一拍
+type PatInfo struct {
+	FromUser   string // 拍的人微信号
+	ChatUser   string // 所在会话,群消息时为群号
+	PattedUser string // 被拍的人微信号
+	PatSuffix  string // 拍一拍后缀
+	Template   string // 提示模板,例如"${wxid_a}" 拍了拍 "${wxid_b}"
+}
+
+// ParseSysMessage 解析MsgTypeSysNotice类型消息中的sysmsg
+func ParseSysMessage(xml string) (*SysMessage, error) {
+	e, err := findElement(xml, "//sysmsg")
+	if err != nil {
+		return nil, err
+	}
+	m := &SysMessage{Type: attr(e, "type"), Raw: xml}
+	switch m.Type {
+	case SysMsgTypeRevoke:
+		r := e.SelectElement("revokemsg")
+		if r == nil {
+			break
+		}
+		m.Revoke = &RevokeInfo{
+			Session:    childText(r, "session"),
+			ReplaceMsg: childText(r, "replacemsg"),
+		}
+		m.Revoke.MsgID, _ = strconv.ParseInt(childText(r, "msgid"), 10, 64)
+		m.Revoke.NewMsgID, _ = strconv.ParseInt(childText(r, "newmsgid"), 10, 64)
+	case SysMsgTypePat:
+		p := e.SelectElement("pat")
+		if p == nil {
+			break
+		}
+		m.Pat = &PatInfo{
+			FromUser:   childText(p, "fromusername"),
+			ChatUser:   childText(p, "chatusername"),
+			PattedUser: childText(p, "pattedusername"),
+			PatSuffix:  childText(p, "patsuffix"),
+			Template:   childText(p, "template"),
+		}
+	}
+	return m, nil
+}
+
+// IsPatted 被拍的是否为user,一般传入UserMessage.ClientUserName判断机器人是否被拍
+func (p *PatInfo) IsPatted(user string) bool {
+	return p.PattedUser == user
+}
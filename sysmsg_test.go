@@ -0,0 +1,67 @@
+package chatbot
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+const (
+	testRevokeXML = `<sysmsg type="revokemsg"><revokemsg><session>123@chatroom</session><msgid>1076543210</msgid><newmsgid>7421852369874563210</newmsgid><replacemsg><![CDATA["小明" 撤回了一条消息]]></replacemsg></revokemsg></sysmsg>`
+	testPatXML    = `<sysmsg type="pat"><pat><fromusername>wxid_a</fromusername><chatusername>123@chatroom</chatusername><pattedusername>wxid_bot</pattedusername><patsuffix><![CDATA[]]></patsuffix><template><![CDATA["${wxid_a}" 拍了拍 "${wxid_bot}"]]></template></pat></sysmsg>`
+)
+
+func TestParseSysMessage(t *testing.T) {
+	m, err := ParseSysMessage(testRevokeXML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Revoke == nil || m.Revoke.NewMsgID != 7421852369874563210 || m.Revoke.Session != "123@chatroom" || m.Revoke.ReplaceMsg != `"小明" 撤回了一条消息` {
+		t.Errorf("unexpected revoke: %+v", m.Revoke)
+	}
+
+	m, err = ParseSysMessage(testPatXML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Pat == nil || m.Pat.FromUser != "wxid_a" || !m.Pat.IsPatted("wxid_bot") {
+		t.Errorf("unexpected pat: %+v", m.Pat)
+	}
+}
+
+func TestRouter_Do(t *testing.T) {
+	var text, pat, revoke, system int
+	r := NewRouter("router")
+	r.OnMessage(MsgTypeText, func(ctx context.Context, msg *UserMessage) error {
+		text++
+		return nil
+	})
+	r.OnPat(func(ctx context.Context, msg *UserMessage, p *PatInfo) error {
+		pat++
+		return nil
+	})
+	r.OnRevoke(func(ctx context.Context, msg *UserMessage, rv *RevokeInfo) error {
+		revoke++
+		return nil
+	})
+	r.OnSystem(func(ctx context.Context, msg *UserMessage) error {
+		system++
+		return nil
+	})
+
+	for _, m := range []*UserMessage{
+		{MsgType: MsgTypeText, Content: "hello"},
+		{MsgType: MsgTypeSysNotice, Content: testPatXML},
+		{MsgType: MsgTypeSysNotice, FromUser: "123@chatroom", GroupContent: testRevokeXML},
+		// 不是<sysmsg>的系统通知交给OnSystem
+		{MsgType: MsgTypeSysNotice, Content: "你已添加了小明，现在可以开始聊天了。"},
+	} {
+		data, _ := json.Marshal(m)
+		if err := r.Do(&PushMessage{MsgType: CusMsgTypeUser, Data: data}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if text != 1 || pat != 1 || revoke != 1 || system != 1 {
+		t.Errorf("unexpected dispatch: text=%d pat=%d revoke=%d system=%d", text, pat, revoke, system)
+	}
+}
@@ -0,0 +1,176 @@
+package chatbot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+// 生成封面的默认尺寸
+const (
+	defaultThumbWidth  = 320
+	defaultThumbHeight = 180
+	// maxThumbSize 生成封面的最大边长,超出时使用默认尺寸
+	maxThumbSize = 1024
+)
+
+// ThumbnailProvider 为没有封面的视频提供封面地址,例如用ffmpeg截取第一帧后上传
+type ThumbnailProvider interface {
+	// Thumbnail 返回封面图片地址
+	// info为收到的视频消息中解析出的信息,发送非转发的视频时为nil
+	Thumbnail(ctx context.Context, videoUrl string, info *VideoInfo) (string, error)
+}
+
+// ThumbnailFunc 函数形式的ThumbnailProvider
+type ThumbnailFunc func(ctx context.Context, videoUrl string, info *VideoInfo) (string, error)
+
+func (f ThumbnailFunc) Thumbnail(ctx context.Context, videoUrl string, info *VideoInfo) (string, error) {
+	return f(ctx, videoUrl, info)
+}
+
+// ForwardVideo 转发收到的视频消息
+// 优先使用原视频的封面,拿不到时和SendVideo一样自动生成封面
+func (bot *ChatBot) ForwardVideo(toUser string, msg *UserMessage) error {
+	content := msg.Content
+	if IsGroupMessage(msg.FromUser) {
+		content = msg.GroupContent
+	}
+	rsp, err := bot.DownloadVideo(content)
+	if err != nil {
+		return err
+	}
+	info, _ := ParseVideoXML(content)
+	thumbUrl, err := bot.sourceThumbnail(info)
+	if err != nil {
+		log.Println("获取原视频封面失败,使用生成的封面:", err)
+		thumbUrl, err = bot.thumbnail(rsp.VideoUrl, info)
+		if err != nil {
+			return err
+		}
+	}
+	return bot.SendVideo(toUser, rsp.VideoUrl, thumbUrl)
+}
+
+// sourceThumbnail 获取原视频封面的地址
+// 视频封面和图片一样是加密的cdn文件,拼成图片消息的xml后通过下载图片接口解密
+func (bot *ChatBot) sourceThumbnail(info *VideoInfo) (string, error) {
+	if info == nil || info.ThumbUrl == "" {
+		return "", errors.New("video has no thumbnail")
+	}
+	if isHTTPUrl(info.ThumbUrl) {
+		return info.ThumbUrl, nil
+	}
+	if info.ThumbAesKey == "" {
+		return "", errors.New("video thumbnail has no aeskey")
+	}
+	rsp, err := bot.DownloadPic(thumbImageXML(info))
+	if err != nil {
+		return "", err
+	}
+	if rsp.ImgUrl == "" {
+		return "", fmt.Errorf("download thumbnail failed:%s", rsp.Content)
+	}
+	return rsp.ImgUrl, nil
+}
+
+// thumbImageXML 把视频封面拼成图片消息的xml,封面同时作为缩略图和中图
+func thumbImageXML(info *VideoInfo) string {
+	doc := etree.NewDocument()
+	img := doc.CreateElement("msg").CreateElement("img")
+	length := strconv.FormatInt(info.ThumbLength, 10)
+	img.CreateAttr("aeskey", info.ThumbAesKey)
+	img.CreateAttr("cdnthumbaeskey", info.ThumbAesKey)
+	img.CreateAttr("cdnthumburl", info.ThumbUrl)
+	img.CreateAttr("cdnthumblength", length)
+	img.CreateAttr("cdnthumbwidth", strconv.Itoa(info.ThumbWidth))
+	img.CreateAttr("cdnthumbheight", strconv.Itoa(info.ThumbHeight))
+	img.CreateAttr("cdnmidimgurl", info.ThumbUrl)
+	img.CreateAttr("length", length)
+	xml, _ := doc.WriteToString()
+	return xml
+}
+
+// thumbnail 依次尝试ThumbnailProvider、默认封面、生成的占位封面
+// ThumbnailProvider失败时会记录日志并继续尝试后面的方式,都不可用时返回它的错误
+func (bot *ChatBot) thumbnail(videoUrl string, info *VideoInfo) (string, error) {
+	var providerErr error
+	if bot.thumbs != nil {
+		u, err := bot.thumbs.Thumbnail(bot.context(), videoUrl, info)
+		if err == nil && u != "" {
+			return u, nil
+		}
+		if err == nil {
+			err = errors.New("thumbnail provider returned empty url")
+		}
+		providerErr = fmt.Errorf("获取视频封面失败:%w", err)
+		log.Println(providerErr)
+	}
+	if bot.defaultThumb != "" {
+		return bot.defaultThumb, nil
+	}
+	if bot.files != nil {
+		data, err := renderThumbnail(info)
+		if err != nil {
+			return "", err
+		}
+		return bot.files.AddBytes("thumb.png", data), nil
+	}
+	if providerErr != nil {
+		return "", providerErr
+	}
+	return "", errors.New("thumbUrl is empty, configure WithThumbnailProvider, WithDefaultThumb or WithFileServer")
+}
+
+// renderThumbnail 根据视频尺寸生成一张带播放按钮的占位封面
+func renderThumbnail(info *VideoInfo) ([]byte, error) {
+	w, h := defaultThumbWidth, defaultThumbHeight
+	// 尺寸来自收到的xml,不可信
+	if info != nil && validThumbSize(info.ThumbWidth) && validThumbSize(info.ThumbHeight) {
+		w, h = info.ThumbWidth, info.ThumbHeight
+	}
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	bg := color.RGBA{R: 0x2b, G: 0x2b, B: 0x2b, A: 0xff}
+	fg := color.RGBA{R: 0xee, G: 0xee, B: 0xee, A: 0xff}
+
+	// 以短边的1/4为大小画一个居中的播放三角形
+	size := w
+	if h < size {
+		size = h
+	}
+	size /= 4
+	cx, cy := w/2, h/2
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetRGBA(x, y, bg)
+			dx, dy := x-(cx-size/2), y-cy
+			if dy < 0 {
+				dy = -dy
+			}
+			if dx >= 0 && dx <= size && dy*2 <= size-dx {
+				img.SetRGBA(x, y, fg)
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func validThumbSize(n int) bool {
+	return n >= 1 && n <= maxThumbSize
+}
+
+func isHTTPUrl(u string) bool {
+	return strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://")
+}
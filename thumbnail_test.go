@@ -0,0 +1,70 @@
+package chatbot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"image/png"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRenderThumbnail(t *testing.T) {
+	data, err := renderThumbnail(&VideoInfo{ThumbWidth: 224, ThumbHeight: 398})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 224 || b.Dy() != 398 {
+		t.Errorf("unexpected thumbnail size %v", b)
+	}
+}
+
+func TestRenderThumbnail_HugeSize(t *testing.T) {
+	data, err := renderThumbnail(&VideoInfo{ThumbWidth: 2000000000, ThumbHeight: 398})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != defaultThumbWidth || b.Dy() != defaultThumbHeight {
+		t.Errorf("want default size for huge dimensions, got %v", b)
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	var xml string
+	bot := newTestBot(t, func(w http.ResponseWriter, r *http.Request) {
+		req := &DownloadImageRequest{}
+		_ = json.NewDecoder(r.Body).Decode(req)
+		xml = req.XML
+		_, _ = w.Write([]byte(`{"code":0,"data":{"imgUrl":"http://cdn/thumb.jpg"}}`))
+	})
+	info := &VideoInfo{ThumbUrl: "3057020100", ThumbAesKey: "a2", ThumbLength: 5120, ThumbWidth: 224, ThumbHeight: 398}
+	u, err := bot.sourceThumbnail(info)
+	if err != nil || u != "http://cdn/thumb.jpg" {
+		t.Fatalf("want decrypted thumbnail url, got %s %v", u, err)
+	}
+	img, err := ParseImageXML(xml)
+	if err != nil || img.MidUrl != info.ThumbUrl || img.AesKey != info.ThumbAesKey || img.Length != 5120 {
+		t.Errorf("unexpected image xml %s: %+v", xml, img)
+	}
+
+	bot.thumbs = ThumbnailFunc(func(ctx context.Context, videoUrl string, info *VideoInfo) (string, error) {
+		return "", errors.New("ffmpeg not found")
+	})
+	if _, err := bot.thumbnail("http://cdn/v.mp4", nil); err == nil || !strings.Contains(err.Error(), "ffmpeg not found") {
+		t.Errorf("want provider error, got %v", err)
+	}
+	bot.defaultThumb = "http://cdn/default.jpg"
+	if u, err := bot.thumbnail("http://cdn/v.mp4", nil); err != nil || u != bot.defaultThumb {
+		t.Errorf("want default thumb, got %s %v", u, err)
+	}
+}
@@ -0,0 +1,57 @@
+package chatbot
+
+import "context"
+
+// Tracer 链路追踪接口,语义和OpenTelemetry的trace.Tracer一致
+// 可以使用tracing子包中的实现,也可以包装OpenTelemetry SDK后通过WithTracer接入
+type Tracer interface {
+	// Start 开始一个span,ctx中如果已经有span则作为其子span
+	Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span)
+}
+
+// Span 一次追踪中的单个操作
+type Span interface {
+	SetAttributes(attrs ...Attribute)
+	RecordError(err error)
+	End()
+}
+
+// Attribute span上的属性
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Attr 新建一个span属性
+func Attr(key string, value interface{}) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// span中使用的属性名
+const (
+	AttrPushType = "chatbot.push_type"
+	AttrMsgType  = "chatbot.msg_type"
+	AttrNewMsgID = "chatbot.new_msg_id"
+	AttrFromUser = "chatbot.from_user"
+	AttrPlugin   = "chatbot.plugin"
+	AttrEndpoint = "chatbot.endpoint"
+)
+
+// nopTracer 默认不做任何追踪
+type nopTracer struct{}
+
+func (nopTracer) Start(ctx context.Context, _ string, _ ...Attribute) (context.Context, Span) {
+	return ctx, nopSpan{}
+}
+
+type nopSpan struct{}
+
+func (nopSpan) SetAttributes(...Attribute) {}
+func (nopSpan) RecordError(error)          {}
+func (nopSpan) End()                       {}
+
+// StartSpan 使用WithTracer配置的Tracer开始一个span
+// 插件中脱离当前消息处理流程的异步任务可以用它创建自己的span
+func (bot *ChatBot) StartSpan(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	return bot.bot.tracer.Start(ctx, name, attrs...)
+}
@@ -0,0 +1,155 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// StdoutExporter 把span以每行一个json的形式写出
+type StdoutExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewStdoutExporter 新建写出到w的导出器,一般传入os.Stdout
+func NewStdoutExporter(w io.Writer) *StdoutExporter {
+	return &StdoutExporter{w: w}
+}
+
+func (e *StdoutExporter) Export(service string, spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	enc := json.NewEncoder(e.w)
+	for _, s := range spans {
+		attrs := make(map[string]interface{}, len(s.Attributes))
+		for _, a := range s.Attributes {
+			attrs[a.Key] = a.Value
+		}
+		if err := enc.Encode(map[string]interface{}{
+			"service":      service,
+			"traceId":      s.TraceID,
+			"spanId":       s.SpanID,
+			"parentSpanId": s.ParentSpanID,
+			"name":         s.Name,
+			"start":        s.Start,
+			"duration":     s.End.Sub(s.Start).String(),
+			"attributes":   attrs,
+			"error":        s.Err,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OTLPExporter 以OTLP/HTTP JSON协议发送到collector
+type OTLPExporter struct {
+	endpoint string
+	client   *http.Client
+}
+
+// NewOTLPExporter 新建OTLP导出器
+// @endpoint collector的完整地址,例如 http://127.0.0.1:4318/v1/traces
+func NewOTLPExporter(endpoint string) *OTLPExporter {
+	return &OTLPExporter{
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (e *OTLPExporter) Export(service string, spans []SpanData) error {
+	otlpSpans := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		o := otlpSpan{
+			TraceID:           s.TraceID,
+			SpanID:            s.SpanID,
+			ParentSpanID:      s.ParentSpanID,
+			Name:              s.Name,
+			Kind:              1,
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+			Attributes:        make([]otlpKeyValue, 0, len(s.Attributes)),
+		}
+		for _, a := range s.Attributes {
+			o.Attributes = append(o.Attributes, otlpAttr(a.Key, a.Value))
+		}
+		if s.Err != "" {
+			o.Status = &otlpStatus{Code: 2, Message: s.Err}
+		}
+		otlpSpans = append(otlpSpans, o)
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": []otlpKeyValue{otlpAttr("service.name", service)},
+				},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]string{"name": "github.com/chatrbot/chatbot-go"},
+						"spans": otlpSpans,
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	rsp, err := e.client.Post(e.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	_ = rsp.Body.Close()
+	if rsp.StatusCode/100 != 2 {
+		return fmt.Errorf("otlp collector status code err:%d", rsp.StatusCode)
+	}
+	return nil
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes"`
+	Status            *otlpStatus    `json:"status,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type otlpKeyValue struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+func otlpAttr(key string, value interface{}) otlpKeyValue {
+	var v map[string]interface{}
+	switch val := value.(type) {
+	case bool:
+		v = map[string]interface{}{"boolValue": val}
+	case int:
+		v = map[string]interface{}{"intValue": strconv.Itoa(val)}
+	case int64:
+		v = map[string]interface{}{"intValue": strconv.FormatInt(val, 10)}
+	case float64:
+		v = map[string]interface{}{"doubleValue": val}
+	case string:
+		v = map[string]interface{}{"stringValue": val}
+	default:
+		v = map[string]interface{}{"stringValue": fmt.Sprint(val)}
+	}
+	return otlpKeyValue{Key: key, Value: v}
+}
@@ -0,0 +1,199 @@
+// Package tracing 提供chatbot.Tracer的轻量实现
+// 生成的trace/span id与OpenTelemetry兼容,可以导出到标准输出或者OTLP/HTTP协议的本地collector
+//
+//	t := tracing.New(tracing.NewOTLPExporter("http://127.0.0.1:4318/v1/traces"), "my-bot")
+//	defer t.Close()
+//	bot, err := chatbot.New(host, token, chatbot.WithTracer(t))
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/chatrbot/chatbot-go"
+)
+
+const (
+	// 批量导出的最大span数量
+	batchSize = 128
+	// 批量导出的间隔
+	flushInterval = 2 * time.Second
+)
+
+// SpanData 已经结束的span数据
+type SpanData struct {
+	TraceID      string
+	SpanID       string
+	ParentSpanID string
+	Name         string
+	Start        time.Time
+	End          time.Time
+	Attributes   []chatbot.Attribute
+	Err          string
+}
+
+// Exporter span导出器
+type Exporter interface {
+	Export(service string, spans []SpanData) error
+}
+
+// Tracer chatbot.Tracer的实现,span结束后批量交给Exporter导出
+type Tracer struct {
+	service  string
+	exporter Exporter
+	queue    chan SpanData
+	done     chan struct{}
+
+	// mu 保护closed,关闭queue和往queue发送都需要持有
+	mu     sync.Mutex
+	closed bool
+}
+
+var _ chatbot.Tracer = new(Tracer)
+
+// New 新建Tracer
+// @exporter 导出器
+// @service 服务名,对应OpenTelemetry中的service.name
+func New(exporter Exporter, service string) *Tracer {
+	t := &Tracer{
+		service:  service,
+		exporter: exporter,
+		queue:    make(chan SpanData, batchSize*8),
+		done:     make(chan struct{}),
+	}
+	go t.loop()
+	return t
+}
+
+// Close 导出剩余的span并停止
+func (t *Tracer) Close() {
+	t.mu.Lock()
+	if !t.closed {
+		t.closed = true
+		close(t.queue)
+	}
+	t.mu.Unlock()
+	<-t.done
+}
+
+// enqueue 把结束的span放入导出队列,Close之后结束的span直接丢弃
+func (t *Tracer) enqueue(d SpanData) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return
+	}
+	select {
+	case t.queue <- d:
+	default:
+		log.Println("span队列已满,丢弃:", d.Name)
+	}
+}
+
+func (t *Tracer) Start(ctx context.Context, name string, attrs ...chatbot.Attribute) (context.Context, chatbot.Span) {
+	s := &span{
+		tracer: t,
+		data: SpanData{
+			SpanID:     newID(8),
+			Name:       name,
+			Start:      time.Now(),
+			Attributes: attrs,
+		},
+	}
+	if parent, ok := ctx.Value(spanKey{}).(*span); ok {
+		s.data.TraceID = parent.data.TraceID
+		s.data.ParentSpanID = parent.data.SpanID
+	} else {
+		s.data.TraceID = newID(16)
+	}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+func (t *Tracer) loop() {
+	defer close(t.done)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]SpanData, 0, batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := t.exporter.Export(t.service, batch); err != nil {
+			log.Println("导出span失败:", err)
+		}
+		batch = make([]SpanData, 0, batchSize)
+	}
+	for {
+		select {
+		case d, ok := <-t.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, d)
+			if len(batch) >= batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+type spanKey struct{}
+
+type span struct {
+	mu     sync.Mutex
+	tracer *Tracer
+	data   SpanData
+	ended  bool
+}
+
+func (s *span) SetAttributes(attrs ...chatbot.Attribute) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Attributes = append(s.data.Attributes, attrs...)
+}
+
+func (s *span) RecordError(err error) {
+	if err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Err = err.Error()
+}
+
+func (s *span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	d := s.data
+	s.mu.Unlock()
+
+	s.tracer.enqueue(d)
+}
+
+// SpanFromContext 获取ctx中当前的trace id和span id,没有时返回空字符串
+// 可以用于在日志中关联追踪
+func SpanFromContext(ctx context.Context) (traceID, spanID string) {
+	if s, ok := ctx.Value(spanKey{}).(*span); ok {
+		return s.data.TraceID, s.data.SpanID
+	}
+	return "", ""
+}
+
+func newID(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,77 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/chatrbot/chatbot-go"
+)
+
+type memExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+func (e *memExporter) Export(_ string, spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func TestTracer_ParentChild(t *testing.T) {
+	exp := &memExporter{}
+	tr := New(exp, "test")
+
+	ctx, root := tr.Start(context.Background(), "chatbot.receive", chatbot.Attr(chatbot.AttrNewMsgID, int64(1)))
+	pctx, plugin := tr.Start(ctx, "chatbot.plugin")
+	_, api := tr.Start(pctx, "chatbot.api")
+	api.RecordError(errors.New("boom"))
+	api.End()
+	plugin.End()
+	root.End()
+	tr.Close()
+
+	if len(exp.spans) != 3 {
+		t.Fatalf("want 3 spans, got %d", len(exp.spans))
+	}
+	a, p, r := exp.spans[0], exp.spans[1], exp.spans[2]
+	if a.TraceID != r.TraceID || p.TraceID != r.TraceID {
+		t.Error("spans not in the same trace")
+	}
+	if a.ParentSpanID != p.SpanID || p.ParentSpanID != r.SpanID || r.ParentSpanID != "" {
+		t.Error("wrong parent span id")
+	}
+	if a.Err != "boom" {
+		t.Errorf("want error recorded, got %q", a.Err)
+	}
+}
+
+func TestTracer_EndAfterClose(t *testing.T) {
+	exp := &memExporter{}
+	tr := New(exp, "test")
+	_, s := tr.Start(context.Background(), "chatbot.receive")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, s := tr.Start(context.Background(), "chatbot.api")
+			s.End()
+		}()
+	}
+	tr.Close()
+	wg.Wait()
+	// Close之后结束的span被丢弃,不能panic
+	s.End()
+	tr.Close()
+
+	for _, d := range exp.spans {
+		if d.Name == "chatbot.receive" {
+			t.Error("want span ended after Close dropped")
+		}
+	}
+}
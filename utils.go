@@ -1,6 +1,9 @@
 package chatbot
 
 import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
 	"strings"
 )
 
@@ -9,19 +12,21 @@ func IsGroupMessage(userName string) bool {
 	return strings.HasSuffix(userName, "@chatroom")
 }
 
-// IsBotBeenAt 机器人是否被@了
+// IsBotBeenAt 机器人是否被@了,@所有人也算
 func IsBotBeenAt(msg *UserMessage) bool {
 	for _, u := range msg.AtList {
 		if msg.ClientUserName == u {
 			return true
 		}
 	}
-	return false
+	return msg.IsAtAll()
 }
 
 // SplitAtContent 分割包含@的消息内容
 // 例如 @小明 你吃饭了么
 // 其中消息体中间会有个"空格"，这个可能是个特殊字符，也可能是个真的空格
+//
+// Deprecated: 只能处理开头的一个@,请使用ParseMentions或者UserMessage.Mentions
 func SplitAtContent(msgContent string) string {
 	content := msgContent
 
@@ -34,3 +39,17 @@ func SplitAtContent(msgContent string) string {
 	}
 	return content
 }
+
+// writeJSONFile 把v以json格式写入path
+// 先写入临时文件再重命名,写入过程中出错不会破坏原文件
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
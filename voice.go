@@ -0,0 +1,69 @@
+package chatbot
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/chatrbot/chatbot-go/silk"
+)
+
+// ErrNoVoiceEncoder 发送WAV语音时没有通过WithVoiceEncoder配置编码器
+var ErrNoVoiceEncoder = errors.New("voice encoder is not configured")
+
+// VoiceEncoder 把WAV编码为微信语音使用的silk格式
+// 本库不包含SILK编码实现,需要自行实现,silk子包提供了WAV读取和SILK文件封装的辅助函数
+type VoiceEncoder interface {
+	FromWAV(wav []byte) ([]byte, error)
+}
+
+// isWAVUrl 地址是否指向WAV文件
+func isWAVUrl(rawUrl string) bool {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(path.Ext(u.Path), ".wav")
+}
+
+// toSilk WAV数据转为silk,其他数据原样返回
+func (bot *ChatBot) toSilk(data []byte) ([]byte, error) {
+	if !silk.IsWAV(data) {
+		return data, nil
+	}
+	if bot.voice == nil {
+		return nil, ErrNoVoiceEncoder
+	}
+	return bot.voice.FromWAV(data)
+}
+
+// wavUrlToSilk 下载WAV地址的内容并转为silk,返回临时文件服务中的地址
+func (bot *ChatBot) wavUrlToSilk(wavUrl string) (string, error) {
+	if bot.files == nil {
+		return "", ErrNoFileServer
+	}
+	req, err := http.NewRequestWithContext(bot.context(), http.MethodGet, wavUrl, nil)
+	if err != nil {
+		return "", err
+	}
+	rsp, err := bot.httpClient().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return "", &StatusError{StatusCode: rsp.StatusCode}
+	}
+	wav, err := ioutil.ReadAll(&limitedReadCloser{r: rsp.Body, n: bot.maxMediaSize()})
+	if err != nil {
+		return "", err
+	}
+	voice, err := bot.toSilk(wav)
+	if err != nil {
+		return "", err
+	}
+	return bot.files.AddBytes("voice.silk", voice), nil
+}
@@ -0,0 +1,304 @@
+// Package webhook 提供把收到的消息转发到http地址的插件
+// 非Go服务不需要实现Plugin,只需要提供一个接收POST的地址即可处理机器人消息
+//
+// 转发的请求体为解析后的消息,用户消息为chatbot.UserMessage,群事件为chatbot.GroupBotEvent,
+// 其他推送类型为data原文,推送类型通过请求头区分:
+//
+//	X-Chatbot-Push-Type: 推送消息类型,例如10000
+//
+// 配置了Secret时会携带签名头:
+//
+//	X-Chatbot-Timestamp: unix秒级时间戳
+//	X-Chatbot-Signature: hex(hmac_sha256(Secret, Timestamp + "." + Body))
+//
+// 响应体可以携带回复内容,机器人会回复到消息来源(私聊、群或者群事件对应的群)
+//
+//	{"reply":"收到","atList":["wxid_xxx"]}
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/chatrbot/chatbot-go"
+)
+
+// AttrTarget span上记录的转发地址
+const AttrTarget = "webhook.target"
+
+const (
+	defaultTimeout    = 10 * time.Second
+	defaultMaxRetries = 3
+	defaultBackoff    = time.Second
+)
+
+// Filter 转发过滤条件,各字段为空时不限制,多个字段同时配置时需要全部满足
+type Filter struct {
+	// PushTypes 推送消息类型,例如chatbot.CusMsgTypeUser
+	PushTypes []chatbot.PushMsgType
+	// Groups 群微信号,配置后私聊消息不会转发
+	Groups []string
+	// Senders 发送人微信号,群消息为群内发言人
+	Senders []string
+	// MsgTypes 用户消息的具体类型,例如chatbot.MsgTypeText
+	MsgTypes []int
+}
+
+// Target 转发地址
+type Target struct {
+	URL string
+	// Secret 签名密钥,为空时不签名
+	Secret string
+	Filter Filter
+}
+
+// Reply 转发地址返回的回复
+type Reply struct {
+	Reply  string   `json:"reply"`
+	AtList []string `json:"atList"`
+}
+
+// Forwarder 转发插件
+type Forwarder struct {
+	bot     *chatbot.ChatBot
+	name    string
+	targets []Target
+	client  *http.Client
+
+	// MaxRetries 失败后最多重试次数
+	MaxRetries int
+	// Backoff 第一次重试的等待时间,之后每次翻倍
+	Backoff time.Duration
+}
+
+var _ chatbot.Plugin = new(Forwarder)
+
+// New 新建转发插件
+func New(bot *chatbot.ChatBot, targets ...Target) *Forwarder {
+	return &Forwarder{
+		bot:        bot,
+		name:       "WebhookForwarder",
+		targets:    targets,
+		client:     &http.Client{Timeout: defaultTimeout},
+		MaxRetries: defaultMaxRetries,
+		Backoff:    defaultBackoff,
+	}
+}
+
+func (f *Forwarder) Name() string {
+	return f.name
+}
+
+// Do 异步转发到所有匹配的地址,不会阻塞其他插件
+func (f *Forwarder) Do(msg *chatbot.PushMessage) error {
+	m, err := decode(msg)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(m.payload)
+	if err != nil {
+		return err
+	}
+	// 插件返回后span就结束了,转发使用不会被取消的ctx并且创建自己的span
+	ctx := detach(msg.Context())
+	for _, t := range f.targets {
+		if !t.Filter.match(m) {
+			continue
+		}
+		go f.forward(ctx, t, m, body)
+	}
+	return nil
+}
+
+// forward 转发并处理回复
+func (f *Forwarder) forward(ctx context.Context, t Target, m *message, body []byte) {
+	ctx, span := f.bot.StartSpan(ctx, "webhook.forward", chatbot.Attr(AttrTarget, t.URL))
+	defer span.End()
+
+	rsp, err := f.postWithRetry(ctx, t, m.pushType, body)
+	if err != nil {
+		span.RecordError(err)
+		log.Printf("转发消息到%s失败:%s\n", t.URL, err)
+		return
+	}
+	if len(bytes.TrimSpace(rsp)) == 0 {
+		return
+	}
+	var reply Reply
+	if err := json.Unmarshal(rsp, &reply); err != nil {
+		span.RecordError(err)
+		log.Printf("解析%s的回复失败:%s\n", t.URL, err)
+		return
+	}
+	if reply.Reply == "" || m.replyTo == "" {
+		return
+	}
+	if err := f.bot.WithContext(ctx).SendText(m.replyTo, reply.Reply, reply.AtList); err != nil {
+		span.RecordError(err)
+		log.Printf("发送%s的回复失败:%s\n", t.URL, err)
+	}
+}
+
+func (f *Forwarder) postWithRetry(ctx context.Context, t Target, pushType chatbot.PushMsgType, body []byte) ([]byte, error) {
+	backoff := f.Backoff
+	var err error
+	for i := 0; i <= f.MaxRetries; i++ {
+		if i > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		var rsp []byte
+		var retry bool
+		rsp, retry, err = f.post(ctx, t, pushType, body)
+		if err == nil || !retry {
+			return rsp, err
+		}
+	}
+	return nil, err
+}
+
+// post 发送一次请求,返回的retry表示失败后是否需要重试
+func (f *Forwarder) post(ctx context.Context, t Target, pushType chatbot.PushMsgType, body []byte) ([]byte, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("X-Chatbot-Push-Type", strconv.Itoa(int(pushType)))
+	if t.Secret != "" {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set("X-Chatbot-Timestamp", ts)
+		req.Header.Set("X-Chatbot-Signature", Sign(t.Secret, ts, body))
+	}
+	rsp, err := f.client.Do(req)
+	if err != nil {
+		return nil, true, err
+	}
+	defer rsp.Body.Close()
+	data, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, true, err
+	}
+	if rsp.StatusCode/100 != 2 {
+		// 4xx为对方拒绝,重试没有意义
+		return nil, rsp.StatusCode >= 500 || rsp.StatusCode == http.StatusTooManyRequests,
+			fmt.Errorf("response status code err:%d", rsp.StatusCode)
+	}
+	return data, false, nil
+}
+
+// Sign 计算请求签名,接收方可以用来校验请求
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// message 用于过滤和回复的消息摘要
+type message struct {
+	// payload 转发的请求体
+	payload  interface{}
+	pushType chatbot.PushMsgType
+	group    string
+	sender   string
+	msgType  int
+	replyTo  string
+}
+
+func decode(msg *chatbot.PushMessage) (*message, error) {
+	m := &message{pushType: msg.MsgType, payload: msg.Data}
+	switch msg.MsgType {
+	case chatbot.CusMsgTypeUser:
+		u := &chatbot.UserMessage{}
+		if err := json.Unmarshal(msg.Data, u); err != nil {
+			return nil, err
+		}
+		m.payload = u
+		m.msgType = u.MsgType
+		m.replyTo = u.FromUser
+		m.sender = u.FromUser
+		if chatbot.IsGroupMessage(u.FromUser) {
+			m.group = u.FromUser
+			m.sender = u.GroupMember
+		}
+	case chatbot.CusMsgTypeGroupEvent:
+		e := &chatbot.GroupBotEvent{}
+		if err := json.Unmarshal(msg.Data, e); err != nil {
+			return nil, err
+		}
+		m.payload = e
+		m.group = e.Group.GroupUserName
+		// 机器人被踢出后无法再往群里发消息
+		if e.Event != chatbot.GroupEventKicked {
+			m.replyTo = e.Group.GroupUserName
+		}
+	}
+	return m, nil
+}
+
+func (f *Filter) match(m *message) bool {
+	if len(f.PushTypes) > 0 {
+		ok := false
+		for _, t := range f.PushTypes {
+			if t == m.pushType {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	if len(f.MsgTypes) > 0 {
+		ok := false
+		for _, t := range f.MsgTypes {
+			if m.pushType == chatbot.CusMsgTypeUser && t == m.msgType {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return contains(f.Groups, m.group) && contains(f.Senders, m.sender)
+}
+
+// contains list为空时不限制
+func contains(list []string, s string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// detached 保留父ctx中的值(例如span),但不会随父ctx取消
+type detached struct {
+	parent context.Context
+}
+
+func detach(ctx context.Context) context.Context {
+	return detached{parent: ctx}
+}
+
+func (detached) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detached) Done() <-chan struct{}               { return nil }
+func (detached) Err() error                          { return nil }
+func (d detached) Value(key interface{}) interface{} { return d.parent.Value(key) }
@@ -0,0 +1,126 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chatrbot/chatbot-go"
+	"github.com/gorilla/websocket"
+)
+
+func TestFilter_Match(t *testing.T) {
+	msg := &chatbot.PushMessage{
+		MsgType: chatbot.CusMsgTypeUser,
+		Data:    []byte(`{"fromUser":"123@chatroom","groupMember":"wxid_a","msgType":1}`),
+	}
+	m, err := decode(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		filter Filter
+		want   bool
+	}{
+		{Filter{}, true},
+		{Filter{Groups: []string{"123@chatroom"}}, true},
+		{Filter{Groups: []string{"456@chatroom"}}, false},
+		{Filter{Senders: []string{"wxid_a"}, MsgTypes: []int{chatbot.MsgTypeText}}, true},
+		{Filter{MsgTypes: []int{chatbot.MsgTypeImg}}, false},
+		{Filter{PushTypes: []chatbot.PushMsgType{chatbot.CusMsgTypeGroupEvent}}, false},
+	}
+	for i, c := range cases {
+		if got := c.filter.match(m); got != c.want {
+			t.Errorf("case %d: want %v, got %v", i, c.want, got)
+		}
+	}
+}
+
+func TestForwarder_PostWithRetry(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if r.Header.Get("X-Chatbot-Signature") != Sign("secret", r.Header.Get("X-Chatbot-Timestamp"), []byte(`{}`)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"reply":"ok"}`))
+	}))
+	defer srv.Close()
+
+	f := New(nil)
+	f.Backoff = time.Millisecond
+	rsp, err := f.postWithRetry(context.Background(), Target{URL: srv.URL, Secret: "secret"}, chatbot.CusMsgTypeUser, []byte(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(rsp) != `{"reply":"ok"}` || atomic.LoadInt32(&calls) != 3 {
+		t.Errorf("unexpected response %s after %d calls", rsp, calls)
+	}
+}
+
+func TestForwarder_Do(t *testing.T) {
+	replies := make(chan chatbot.SendTextRequest, 1)
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ws":
+			c, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer c.Close()
+			for {
+				if _, _, err := c.ReadMessage(); err != nil {
+					return
+				}
+			}
+		case "/hook":
+			// 请求体为解析后的UserMessage,不是推送消息原文
+			var u chatbot.UserMessage
+			_ = json.NewDecoder(r.Body).Decode(&u)
+			if r.Header.Get("X-Chatbot-Push-Type") != strconv.Itoa(int(chatbot.CusMsgTypeUser)) || u.FromUser != "wxid_a" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_, _ = w.Write([]byte(`{"reply":"收到:` + u.Content + `"}`))
+		default:
+			var req chatbot.SendTextRequest
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			replies <- req
+			_, _ = w.Write([]byte(`{"code":0,"data":{}}`))
+		}
+	}))
+	defer srv.Close()
+	bot, err := chatbot.New(strings.TrimPrefix(srv.URL, "http://"), "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bot.Close()
+
+	f := New(bot, Target{URL: srv.URL + "/hook"})
+	err = f.Do(&chatbot.PushMessage{
+		MsgType: chatbot.CusMsgTypeUser,
+		Data:    []byte(`{"fromUser":"wxid_a","msgType":1,"content":"你好"}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case req := <-replies:
+		if req.ToUser != "wxid_a" || req.Content != "收到:你好" {
+			t.Errorf("unexpected reply %+v", req)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("want reply sent")
+	}
+}
@@ -1,6 +1,7 @@
 package chatbot
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -26,23 +27,43 @@ type WsServer struct {
 	host    string
 	token   string
 	con     *websocket.Conn
-	plugins []Plugin
+	metrics Metrics
+	tracer  Tracer
+
+	pluginMu sync.RWMutex
+	plugins  []*pluginEntry
 
 	pingTimer *time.Timer
+	lastPong  time.Time
+}
+
+// pluginEntry 已加载的插件和启用状态
+type pluginEntry struct {
+	plugin  Plugin
+	enabled bool
+}
+
+// PluginState 插件的加载状态
+type PluginState struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
 }
 
 // 新建WebSocket连接
-func newWSClient(host, token string) (*WsServer, error) {
+func newWSClient(host, token string, o *options) (*WsServer, error) {
 	con, err := connect(host, token)
 	if err != nil {
 		return nil, err
 	}
 	log.Println("connect server success")
+	o.metrics.WsConnected()
 	server := &WsServer{
 		con:     con,
-		plugins: make([]Plugin, 0, 10),
+		plugins: make([]*pluginEntry, 0, 10),
 		host:    host,
 		token:   token,
+		metrics: o.metrics,
+		tracer:  o.tracer,
 	}
 	server.startHeartBeat()
 	return server, nil
@@ -83,9 +104,21 @@ func connect(host, token string) (*websocket.Conn, error) {
 // ReceiveCallbackMessage 开始监听服务端消息和调用插件
 func (ws *WsServer) ReceiveCallbackMessage() {
 	for {
-		msgType, msg, err := ws.con.ReadMessage()
+		ws.mu.Lock()
+		con := ws.con
+		ws.mu.Unlock()
+
+		var (
+			msgType int
+			msg     []byte
+			err     = errors.New("WebSocket con is nil")
+		)
+		if con != nil {
+			msgType, msg, err = con.ReadMessage()
+		}
 		if err != nil {
 			log.Println("连接断开,开始重连...")
+			ws.metrics.WsDisconnected()
 			ws.Close()
 			ws.reconnect()
 			ws.startHeartBeat()
@@ -93,20 +126,16 @@ func (ws *WsServer) ReceiveCallbackMessage() {
 			continue
 		}
 		if string(msg) == "pong" {
+			ws.mu.Lock()
+			ws.lastPong = time.Now()
+			ws.mu.Unlock()
 			continue
 		}
 		if msgType == websocket.TextMessage {
 			log.Println("收到消息:", string(msg))
-			if len(ws.plugins) > 0 {
-				var rec PushMessage
-				_ = json.Unmarshal(msg, &rec)
-
-				for _, p := range ws.plugins {
-					if err := p.Do(&rec); err != nil {
-						log.Printf("%s handle error:%s \n", p.Name(), err)
-					}
-				}
-			}
+			var rec PushMessage
+			_ = json.Unmarshal(msg, &rec)
+			ws.dispatch(&rec)
 		}
 	}
 }
@@ -115,7 +144,9 @@ func (ws *WsServer) ReceiveCallbackMessage() {
 func (ws *WsServer) reconnect() {
 	for {
 		con, err := connect(ws.host, ws.token)
+		ws.metrics.WsReconnectAttempt(err)
 		if err == nil {
+			ws.metrics.WsConnected()
 			ws.mu.Lock()
 			ws.con = con
 			ws.mu.Unlock()
@@ -126,6 +157,46 @@ func (ws *WsServer) reconnect() {
 	}
 }
 
+// dispatch 把收到的消息依次交给插件处理
+func (ws *WsServer) dispatch(rec *PushMessage) {
+	msgType := userMsgType(rec)
+	ws.metrics.MessageReceived(rec.MsgType, msgType)
+
+	attrs := []Attribute{Attr(AttrPushType, int(rec.MsgType))}
+	if rec.MsgType == CusMsgTypeUser {
+		attrs = append(attrs,
+			Attr(AttrMsgType, msgType),
+			Attr(AttrNewMsgID, gjson.GetBytes(rec.Data, "newMsgId").Int()),
+			Attr(AttrFromUser, gjson.GetBytes(rec.Data, "fromUser").String()),
+		)
+	}
+	ctx, span := ws.tracer.Start(context.Background(), "chatbot.receive", attrs...)
+	defer span.End()
+
+	for _, p := range ws.enabledPlugins() {
+		pctx, pspan := ws.tracer.Start(ctx, "chatbot.plugin", Attr(AttrPlugin, p.Name()))
+		// 每个插件使用自己的浅拷贝,插件异步读取Context时不会拿到其他插件的span
+		msg := *rec
+		msg.ctx = pctx
+		start := time.Now()
+		err := p.Do(&msg)
+		ws.metrics.PluginHandled(p.Name(), time.Since(start), err)
+		if err != nil {
+			log.Printf("%s handle error:%s \n", p.Name(), err)
+			pspan.RecordError(err)
+		}
+		pspan.End()
+	}
+}
+
+// userMsgType 获取用户消息中的具体消息类型,非用户消息返回0
+func userMsgType(msg *PushMessage) int {
+	if msg.MsgType != CusMsgTypeUser {
+		return 0
+	}
+	return int(gjson.GetBytes(msg.Data, "msgType").Int())
+}
+
 // startHeartBeat 心跳包
 func (ws *WsServer) startHeartBeat() {
 	log.Println("开始发送心跳包")
@@ -143,7 +214,66 @@ func (ws *WsServer) startHeartBeat() {
 
 // addPlugin 添加插件
 func (ws *WsServer) addPlugin(plugin ...Plugin) {
-	ws.plugins = append(ws.plugins, plugin...)
+	ws.pluginMu.Lock()
+	defer ws.pluginMu.Unlock()
+	for _, p := range plugin {
+		ws.plugins = append(ws.plugins, &pluginEntry{plugin: p, enabled: true})
+	}
+}
+
+// enabledPlugins 当前启用的插件
+func (ws *WsServer) enabledPlugins() []Plugin {
+	ws.pluginMu.RLock()
+	defer ws.pluginMu.RUnlock()
+	plugins := make([]Plugin, 0, len(ws.plugins))
+	for _, e := range ws.plugins {
+		if e.enabled {
+			plugins = append(plugins, e.plugin)
+		}
+	}
+	return plugins
+}
+
+// pluginStates 所有插件的启用状态
+func (ws *WsServer) pluginStates() []PluginState {
+	ws.pluginMu.RLock()
+	defer ws.pluginMu.RUnlock()
+	states := make([]PluginState, 0, len(ws.plugins))
+	for _, e := range ws.plugins {
+		states = append(states, PluginState{Name: e.plugin.Name(), Enabled: e.enabled})
+	}
+	return states
+}
+
+// setPluginEnabled 启用或者停用插件,同名插件会一起修改
+func (ws *WsServer) setPluginEnabled(name string, enabled bool) error {
+	ws.pluginMu.Lock()
+	defer ws.pluginMu.Unlock()
+	found := false
+	for _, e := range ws.plugins {
+		if e.plugin.Name() == name {
+			e.enabled = enabled
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("plugin %s not found", name)
+	}
+	return nil
+}
+
+// connected WebSocket当前是否已连接
+func (ws *WsServer) connected() bool {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	return ws.con != nil
+}
+
+// lastPongTime 最后一次收到心跳回复的时间
+func (ws *WsServer) lastPongTime() time.Time {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	return ws.lastPong
 }
 
 func (ws *WsServer) ping() error {
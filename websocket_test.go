@@ -0,0 +1,49 @@
+package chatbot
+
+import (
+	"context"
+	"testing"
+)
+
+type nameKey struct{}
+
+// nameTracer 把span名称和插件名放到ctx中
+type nameTracer struct{}
+
+func (nameTracer) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	for _, a := range attrs {
+		if a.Key == AttrPlugin {
+			name = a.Value.(string)
+		}
+	}
+	return context.WithValue(ctx, nameKey{}, name), nopSpan{}
+}
+
+type keepPlugin struct {
+	name string
+	msg  *PushMessage
+}
+
+func (p *keepPlugin) Name() string { return p.name }
+func (p *keepPlugin) Do(msg *PushMessage) error {
+	p.msg = msg
+	return nil
+}
+
+func TestDispatch_PluginContext(t *testing.T) {
+	a, b := &keepPlugin{name: "a"}, &keepPlugin{name: "b"}
+	ws := &WsServer{metrics: nopMetrics{}, tracer: nameTracer{}}
+	ws.addPlugin(a, b)
+	rec := &PushMessage{MsgType: CusMsgTypeUser, Data: []byte(`{"msgType":1}`)}
+	ws.dispatch(rec)
+
+	// 插件保留的消息在之后读取Context时仍然是自己的span
+	for _, p := range []*keepPlugin{a, b} {
+		if got := p.msg.Context().Value(nameKey{}); got != p.name {
+			t.Errorf("plugin %s: want own context, got %v", p.name, got)
+		}
+	}
+	if rec.ctx != nil {
+		t.Error("want shared message not mutated")
+	}
+}